@@ -1,15 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 
+	"github.com/arenadata/ad-runtime-utils/internal/api"
 	"github.com/arenadata/ad-runtime-utils/internal/config"
 	"github.com/arenadata/ad-runtime-utils/internal/detect"
 	"github.com/arenadata/ad-runtime-utils/internal/exec"
+	"github.com/arenadata/ad-runtime-utils/internal/output"
+	"github.com/arenadata/ad-runtime-utils/internal/supervisor"
+	"github.com/arenadata/ad-runtime-utils/internal/venv"
 	"github.com/coreos/go-systemd/v22/daemon"
 )
 
@@ -18,6 +28,7 @@ const (
 	exitOK         = 0
 	exitUserError  = 1
 	exitParseError = 2
+	exitNotFound   = 2 // same as bigtop-detect-javalibs when no native libraries are found
 )
 
 func Run(args []string, stdout, stderr io.Writer) int {
@@ -29,9 +40,21 @@ func Run(args []string, stdout, stderr io.Writer) int {
 	runtime := fs.String("runtime", "", "Runtime to detect (java, python, etc.)")
 	listAll := fs.Bool("list", false, "List all detected runtimes (default + services)")
 	fs.BoolVar(listAll, "l", false, "shorthand for --list")
+	probeVersions := fs.Bool("probe-versions", false,
+		"With --list, run the version_probe of each detected runtime and show its version")
 	printCACerts := fs.Bool("print-cacerts", false, "When used with --runtime=java, prints the cacerts path and exits")
 	start := fs.Bool("start", false, "Start the service. Use with simple/exec services")
 	supervise := fs.Bool("supervise", false, "Supervise the service. Use with notify systemd services")
+	detectJavaLibs := fs.Bool("detect-javalibs", false, "Print JAVA_NATIVE_PATH for the java runtime and exit")
+	classpath := fs.Bool("classpath", false,
+		"Print the assembled classpath and exit. With --runtime, also prints its env")
+	ensure := fs.Bool("ensure", false, "Create or repair the python virtualenv of the service and print its env")
+	services := fs.String("services", "", "Comma-separated services to start and supervise together. Use with --start")
+	serve := fs.Bool("serve", false,
+		"Serve the HTTP API. With --start --supervise, also reports the supervised service")
+	noCache := fs.Bool("no-cache", false, "Do not read or write the resolution cache enabled with cache.enabled")
+	listen := fs.String("listen", "", "API listen address: host:port or unix:/path (default api.listen or "+
+		api.DefaultListen+")")
 
 	if err := fs.Parse(args); err != nil {
 		return exitParseError
@@ -44,7 +67,42 @@ func Run(args []string, stdout, stderr io.Writer) int {
 	}
 
 	if *listAll {
-		return runList(cfg, stdout, stderr)
+		return runList(cfg, *probeVersions, stdout, stderr)
+	}
+
+	listenAddr := *listen
+	if listenAddr == "" {
+		listenAddr = cfg.API.Listen
+	}
+	if listenAddr == "" {
+		listenAddr = api.DefaultListen
+	}
+	if *serve && !*start {
+		return runServe(*cfgPath, cfg, listenAddr, stderr)
+	}
+	if !*serve {
+		listenAddr = ""
+	}
+
+	if *services != "" {
+		if !*start {
+			fmt.Fprintln(stderr, "--services requires --start")
+			return exitUserError
+		}
+		names := withRequired(cfg, strings.Split(*services, ","))
+		code, superviseErr := superviseServices(*cfgPath, cfg, names, nil, listenAddr, *noCache, stdout, stderr)
+		if superviseErr != nil {
+			fmt.Fprintf(stderr, "supervise services failed: %v\n", superviseErr)
+		}
+		return code
+	}
+
+	if *detectJavaLibs {
+		return runDetectJavaLibs(cfg, *service, stdout, stderr)
+	}
+
+	if *classpath {
+		return runClasspath(cfg, *service, *runtime, stdout, stderr)
 	}
 
 	if *runtime == "" {
@@ -53,19 +111,28 @@ func Run(args []string, stdout, stderr io.Writer) int {
 		return exitUserError
 	}
 
+	if *ensure {
+		return runEnsure(cfg, *service, *runtime, stdout, stderr)
+	}
+
+	// the cache only serves the lookups scripts repeat, the exports and cacerts
+	cache := openCache(cfg, *noCache)
+	// a cache that cannot be written, e.g. without root, is not an error
+	defer func() { _ = cache.Save() }()
+
 	if *printCACerts {
 		if strings.ToLower(*runtime) != "java" {
 			fmt.Fprintln(stderr, "--print-cacerts is only valid with --runtime=java")
 			return exitUserError
 		}
 		var javaHome string
-		javaHome, err = detect.ResolveRuntime(cfg, *service, "java")
+		javaHome, err = cache.ResolveRuntime(cfg, *service, "java")
 		if err != nil {
 			fmt.Fprintf(stderr, "detection failed: %v\n", err)
 			return exitUserError
 		}
 		var cacerts string
-		cacerts, err = detect.FindCACerts(javaHome, nil)
+		cacerts, err = cache.FindCACerts(javaHome, nil)
 		if err != nil {
 			fmt.Fprintf(stderr, "cacerts: %v\n", err)
 			return exitUserError
@@ -74,116 +141,513 @@ func Run(args []string, stdout, stderr io.Writer) int {
 		return exitOK
 	}
 
-	path, err := detect.ResolveRuntime(cfg, *service, *runtime) // TODO add detectEnvName
+	path, err := cache.ResolveRuntime(cfg, *service, *runtime)
 	if err != nil {
 		fmt.Fprintf(stderr, "detection failed: %v\n", err)
 		return exitUserError
 	}
 
-	envName := detectEnvName(cfg, *service, *runtime)
+	exports := detect.Exports(cfg, *service, *runtime, path)
 
 	if *start {
-		if err = startService(*service, envName, path, *cfg, *supervise); err != nil {
-			fmt.Fprintf(stderr, "start service failed: %v\n", err)
+		if *serve && !*supervise {
+			fmt.Fprintln(stderr, "--serve with --start requires --supervise")
 			return exitUserError
 		}
+		// As PID 1 (container entrypoint) the service is always supervised,
+		// so that signals are forwarded and orphans are reaped.
+		if !*supervise && os.Getpid() != 1 {
+			if err = startService(*service, exports, cfg, stdout, stderr); err != nil {
+				fmt.Fprintf(stderr, "start service failed: %v\n", err)
+				return exitUserError
+			}
+			return exitOK
+		}
+		code, superviseErr := superviseServices(
+			*cfgPath, cfg, []string{*service}, []string{*runtime}, listenAddr, *noCache, stdout, stderr)
+		if superviseErr != nil {
+			fmt.Fprintf(stderr, "start service failed: %v\n", superviseErr)
+		}
+		return code
+	}
+
+	printExports(stdout, exports)
+	return exitOK
+}
+
+func printExports(stdout io.Writer, exports []string) {
+	for _, e := range exports {
+		fmt.Fprintf(stdout, "export %s\n", e)
+	}
+}
+
+func runDetectJavaLibs(cfg *config.Config, service string, stdout, stderr io.Writer) int {
+	// JAVA_HOME is not needed when the native path is overridden.
+	javaHome, err := detect.ResolveRuntime(cfg, service, "java")
+	nativePath, nativeErr := detect.ResolveJavaNativePath(cfg, service, javaHome)
+	if nativeErr == nil {
+		fmt.Fprintf(stdout, "export %s=%s\n", detect.JavaNativePathEnv, nativePath)
 		return exitOK
 	}
+	if err != nil {
+		fmt.Fprintf(stderr, "detection failed: %v\n", err)
+		return exitUserError
+	}
+	fmt.Fprintf(stderr, "Unable to find native Java libraries: %v\n", nativeErr)
+	if errors.Is(nativeErr, detect.ErrNativeLibsNotFound) {
+		return exitNotFound
+	}
+	return exitUserError
+}
+
+func runClasspath(cfg *config.Config, service, runtime string, stdout, stderr io.Writer) int {
+	var exports []string
+	if runtime != "" {
+		path, err := detect.ResolveRuntime(cfg, service, runtime)
+		if err != nil {
+			fmt.Fprintf(stderr, "detection failed: %v\n", err)
+			return exitUserError
+		}
+		exports = detect.Exports(cfg, service, runtime, path)
+	}
 
-	fmt.Fprintf(stdout, "export %s=%s\n", envName, path)
+	cp, err := detect.AssembleClasspath(cfg, service)
+	for _, dup := range cp.Duplicates {
+		fmt.Fprintf(stderr, "warning: duplicate artifact %s\n", dup)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "classpath: %v\n", err)
+		return exitUserError
+	}
+	exports = append(exports, fmt.Sprintf("%s=%s", cp.EnvVar, cp))
+
+	printExports(stdout, exports)
 	return exitOK
 }
 
-func detectEnvName(cfg *config.Config, service, runtime string) string {
+func runEnsure(cfg *config.Config, service, runtime string, stdout, stderr io.Writer) int {
+	rtCfg := cfg.Default.Runtimes[runtime]
 	if service != "" {
-		if svc, ok := cfg.Services[service]; ok {
-			if rtCfg, ok2 := svc.Runtimes[runtime]; ok2 && rtCfg.EnvVar != "" {
-				return rtCfg.EnvVar
-			}
-		}
+		rtCfg = cfg.Services[service].Runtimes[runtime]
+	}
+	if rtCfg.Venv == nil {
+		fmt.Fprintf(stderr, "no venv configured for service '%s' runtime '%s'\n", service, runtime)
+		return exitUserError
+	}
+	baseVersion := rtCfg.Venv.BaseVersion
+	if baseVersion == "" {
+		baseVersion = rtCfg.Version
+	}
+	baseHome, err := detect.ResolveRuntimeVersion(cfg, runtime, baseVersion)
+	if err != nil {
+		fmt.Fprintf(stderr, "detection failed: %v\n", err)
+		return exitUserError
 	}
-	if def, ok := cfg.Default.Runtimes[runtime]; ok && def.EnvVar != "" {
-		return def.EnvVar
+
+	res, err := venv.Ensure(venv.Options{
+		Path:         detect.ExpandPath(rtCfg.Venv.Path),
+		BaseHome:     baseHome,
+		BaseVersion:  baseVersion,
+		Requirements: detect.ExpandPath(rtCfg.Venv.Requirements),
+		Output:       stderr,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "ensure venv failed: %v\n", err)
+		return exitUserError
 	}
-	switch strings.ToLower(runtime) {
-	case "java":
-		return "JAVA_HOME"
-	case "python":
-		return "VIRTUAL_ENV"
-	default:
-		return strings.ToUpper(runtime) + "_HOME"
+	if res.Created {
+		fmt.Fprintf(stderr, "venv %s created from %s: %s\n", res.Path, baseHome, res.Reason)
 	}
+	printExports(stdout, detect.Exports(cfg, service, runtime, res.Path))
+	return exitOK
 }
 
-func runList(cfg *config.Config, stdout, stderr io.Writer) int {
+// runList prints the runtimes detected for the defaults and every service. The
+// version probes spawn a process per runtime, so they only run with probeVersions.
+func runList(cfg *config.Config, probeVersions bool, stdout, stderr io.Writer) int {
+	if len(cfg.BigtopDefaults) > 0 {
+		fmt.Fprintf(stdout, "Bigtop defaults (%s):\n", cfg.BigtopDefaultsPath())
+		names := make([]string, 0, len(cfg.BigtopDefaults))
+		for name := range cfg.BigtopDefaults {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stdout, "  %s=%s\n", name, cfg.BigtopDefaults[name])
+		}
+		fmt.Fprintln(stdout)
+	}
+
+	listPackages(cfg, stdout)
+
 	fmt.Fprintln(stdout, "Default runtimes:")
 	for rt := range cfg.Default.Runtimes {
-		p, err := detect.ResolveRuntime(cfg, "", rt)
-		if err != nil {
-			fmt.Fprintf(stderr, "  %s: error: %v\n", rt, err)
-		} else {
-			fmt.Fprintf(stdout, "  %s: %s\n", rt, p)
-		}
+		listRuntime(cfg, "", rt, probeVersions, stdout, stderr)
 	}
 	for svcName, svcCfg := range cfg.Services {
 		fmt.Fprintf(stdout, "\nService %s:\n", svcName)
 		for rt := range svcCfg.Runtimes {
-			p, err := detect.ResolveRuntime(cfg, svcName, rt)
-			if err != nil {
-				fmt.Fprintf(stderr, "  %s: error: %v\n", rt, err)
-			} else {
-				fmt.Fprintf(stdout, "  %s: %s\n", rt, p)
+			listRuntime(cfg, svcName, rt, probeVersions, stdout, stderr)
+		}
+	}
+	return exitOK
+}
+
+// listPackages prints the installations of the default runtimes owned by
+// installed packages, if any.
+func listPackages(cfg *config.Config, stdout io.Writer) {
+	runtimes := make([]string, 0, len(cfg.Default.Runtimes))
+	for rt := range cfg.Default.Runtimes {
+		runtimes = append(runtimes, rt)
+	}
+	sort.Strings(runtimes)
+	var lines []string
+	for _, rt := range runtimes {
+		for _, pkg := range detect.InstalledPackages(cfg, rt) {
+			lines = append(lines, fmt.Sprintf("  %s: %s (%s %s %s)", rt, pkg.Home, pkg.Source, pkg.Name, pkg.Version))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintln(stdout, "Installed packages:")
+	for _, line := range lines {
+		fmt.Fprintln(stdout, line)
+	}
+	fmt.Fprintln(stdout)
+}
+
+func listRuntime(cfg *config.Config, service, rt string, probeVersions bool, stdout, stderr io.Writer) {
+	res, err := detect.Resolve(cfg, service, rt)
+	if err != nil {
+		fmt.Fprintf(stderr, "  %s: error: %v\n", rt, err)
+		return
+	}
+	var details []string
+	if probeVersions {
+		if version, probeErr := detect.ProbeVersion(cfg, rt, res.Path); probeErr == nil {
+			details = append(details, "version "+version)
+		}
+	}
+	switch res.Source {
+	case detect.SourceBigtop:
+		details = append(details, "from "+cfg.BigtopDefaultsPath())
+	case detect.SourceSDKMAN, detect.SourceAsdf, detect.SourceJenv, detect.SourcePyenv,
+		detect.SourceDpkg, detect.SourceRPM:
+		details = append(details, "from "+res.Source)
+	}
+	if len(details) == 0 {
+		fmt.Fprintf(stdout, "  %s: %s\n", rt, res.Path)
+	} else {
+		fmt.Fprintf(stdout, "  %s: %s (%s)\n", rt, res.Path, strings.Join(details, ", "))
+	}
+	for _, s := range res.Skipped {
+		fmt.Fprintf(stdout, "    %s\n", s)
+	}
+}
+
+// runServe serves the HTTP API until SIGINT or SIGTERM. SIGHUP reloads the
+// config at cfgPath, an invalid one is reported and the current one kept.
+func runServe(cfgPath string, cfg *config.Config, listenAddr string, stderr io.Writer) int {
+	l, err := api.Listen(listenAddr)
+	if err != nil {
+		fmt.Fprintf(stderr, "cannot listen on %s: %v\n", listenAddr, err)
+		return exitUserError
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := api.New(cfg)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				next, loadErr := config.Load(cfgPath)
+				if loadErr != nil {
+					fmt.Fprintf(stderr, "reload config %q failed, keeping the current one: %v\n", cfgPath, loadErr)
+					continue
+				}
+				server.SetConfig(next)
+				fmt.Fprintf(stderr, "reloaded config %q\n", cfgPath)
 			}
 		}
+	}()
+	fmt.Fprintf(stderr, "serving API on %s\n", l.Addr())
+	if err = server.Serve(ctx, l); err != nil {
+		fmt.Fprintf(stderr, "serve: %v\n", err)
+		return exitUserError
 	}
 	return exitOK
 }
 
-func startService(service string, envName string, envPath string, cfg config.Config, supervise bool) error {
+// serviceEnv returns the environment of a service process: its env_vars
+// followed by the runtime exports (eg. JAVA_HOME).
+func serviceEnv(srvConfig config.ServiceConfig, exports []string) map[string]string {
+	env := make(map[string]string, len(srvConfig.EnvVars)+len(exports))
+	for name, value := range srvConfig.EnvVars {
+		env[name] = value
+	}
+	for _, e := range exports {
+		name, value, _ := strings.Cut(e, "=")
+		env[name] = os.ExpandEnv(value)
+	}
+	return env
+}
+
+// startService runs the service in the foreground without supervision, with its hooks.
+// Output of the service is routed as configured, inherited output goes to stdout and stderr.
+func startService(service string, exports []string, cfg *config.Config, stdout, stderr io.Writer) error {
 	srvConfig, ok := cfg.Services[service]
 	if !ok {
 		return fmt.Errorf("service %s not found in config", service)
 	}
-	// Append the env for the runtime (eg. JAVA_HOME)
-	if srvConfig.EnvVars == nil {
-		srvConfig.EnvVars = make(map[string]string)
+	if err := supervisor.ValidateHooks(srvConfig); err != nil {
+		return fmt.Errorf("service %s: %w", service, err)
 	}
-	srvConfig.EnvVars[envName] = envPath
-	if !supervise {
-		return exec.RunExecutable(srvConfig.Executable, srvConfig.ExecutableArgs, srvConfig.EnvVars)
+	env := serviceEnv(srvConfig, exports)
+	if err := supervisor.RunHooks(supervisor.StagePreStart, srvConfig.PreStart, env, stderr); err != nil {
+		return err
 	}
-	process, err := exec.RunExecutableAsync(srvConfig.Executable, srvConfig.ExecutableArgs, srvConfig.EnvVars)
+	out, err := output.Open(srvConfig.Output, stdout, stderr)
 	if err != nil {
 		return err
 	}
-	// Run the health checks
-	for _, checkCfg := range srvConfig.HealthChecks {
-		switch checkCfg.Type {
-		case exec.PortHealthCheckType:
-			portheck := exec.PortHealthCheck{
-				PID:    process.Process.Pid,
-				Config: checkCfg,
-			}
-			if err = portheck.Check(); err != nil {
-				if err = process.Process.Signal(os.Interrupt); err != nil {
-					return fmt.Errorf("failed to send interrupt signal to process: %w", err)
-				}
-				return fmt.Errorf("health check failed: %w", err)
-			}
-		default:
-			if err = process.Process.Signal(os.Interrupt); err != nil {
-				return fmt.Errorf("failed to send interrupt signal to process: %w", err)
+	defer out.Close()
+	process, err := exec.RunExecutableAsync(srvConfig.Executable, srvConfig.ExecutableArgs, env, out.Stdout, out.Stderr)
+	if err != nil {
+		return err
+	}
+	if err = supervisor.RunHooks(supervisor.StagePostStart, srvConfig.PostStart, env, stderr); err != nil {
+		_ = process.Process.Signal(os.Interrupt)
+	}
+	waitErr := process.Wait()
+	if hookErr := supervisor.RunHooks(supervisor.StagePostStop, srvConfig.PostStop, env, stderr); hookErr != nil {
+		fmt.Fprintf(stderr, "warning: %v\n", hookErr)
+	}
+	if err != nil {
+		return err
+	}
+	return waitErr
+}
+
+// openCache returns the resolution cache enabled with cache.enabled, nil when it
+// is disabled or noCache is set.
+func openCache(cfg *config.Config, noCache bool) *detect.Cache {
+	if !cfg.Cache.Enabled || noCache {
+		return nil
+	}
+	path := cfg.Cache.Path
+	if path == "" {
+		path = detect.DefaultCachePath
+	}
+	return detect.OpenCache(path, cfg)
+}
+
+// newSupervised returns the supervisor of a configured service with the given
+// runtimes resolved and exported. With no runtimes, all runtimes of the service are used.
+// The cacerts of java are looked up through cache for tls checks verifying certificates.
+func newSupervised(
+	cfg *config.Config,
+	cache *detect.Cache,
+	service string,
+	runtimes []string,
+	stderr io.Writer,
+) (*supervisor.Service, error) {
+	srvConfig, ok := cfg.Services[service]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found in config", service)
+	}
+	if len(runtimes) == 0 {
+		for rt := range srvConfig.Runtimes {
+			runtimes = append(runtimes, rt)
+		}
+		sort.Strings(runtimes)
+	}
+	var exports []string
+	var detected []supervisor.Runtime
+	for _, rt := range runtimes {
+		path, err := detect.ResolveRuntime(cfg, service, rt)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: detection failed: %w", service, err)
+		}
+		exports = append(exports, detect.Exports(cfg, service, rt, path)...)
+		version, _ := detect.ProbeVersion(cfg, rt, path)
+		detected = append(detected, supervisor.Runtime{Name: rt, Path: path, Version: version})
+	}
+	svc := supervisor.New(service, srvConfig, serviceEnv(srvConfig, exports))
+	svc.Runtimes = detected
+	for _, rt := range detected {
+		if rt.Name == "java" && verifiesWithRuntimeTrustStore(srvConfig) {
+			// without cacerts tls checks fall back to the system roots
+			var err error
+			if svc.TrustStore, err = cache.FindCACerts(rt.Path, nil); err != nil {
+				fmt.Fprintf(stderr, "warning: service %s: %v, tls checks use the system roots\n", service, err)
 			}
-			return fmt.Errorf("unknown health check type: %s", checkCfg.Type)
 		}
 	}
-	// Notify systemd daemon that service has started
-	if _, err = daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+	return svc, nil
+}
+
+// verifiesWithRuntimeTrustStore reports whether a tls health check of the service
+// verifies certificates without a truststore of its own.
+func verifiesWithRuntimeTrustStore(srvConfig config.ServiceConfig) bool {
+	for _, hc := range srvConfig.HealthChecks {
+		if hc.Type != config.HealthCheckTLS {
+			continue
+		}
+		params, err := hc.DecodeParams()
+		if p, ok := params.(*config.TLSCheckParams); err == nil && ok && bool(p.Verify) && p.TrustStore == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// withRequired returns services followed by the services they require, transitively.
+func withRequired(cfg *config.Config, services []string) []string {
+	seen := make(map[string]bool, len(services))
+	var out []string
+	var add func(name string)
+	add = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		out = append(out, name)
+		for _, req := range cfg.Services[name].Requires {
+			add(req)
+		}
+	}
+	for _, name := range services {
+		add(name)
+	}
+	return out
+}
+
+// superviseServices starts the services in dependency order, notifies systemd once
+// all of them passed their health checks and supervises them until they stop or
+// SIGINT/SIGTERM is received. SIGHUP reloads the config at cfgPath, see reloadServices.
+// When listenAddr is set, the HTTP API is served meanwhile.
+// Services inheriting their output write to stdout and stderr.
+// It returns the exit code of the first service that failed, see supervisor.Group.ExitCode.
+func superviseServices(
+	cfgPath string,
+	cfg *config.Config,
+	services, runtimes []string,
+	listenAddr string,
+	noCache bool,
+	stdout, stderr io.Writer,
+) (int, error) {
+	stdout, stderr = output.Synchronized(stdout), output.Synchronized(stderr)
+	cache := openCache(cfg, noCache)
+	supervised := make([]*supervisor.Service, 0, len(services))
+	for _, name := range services {
+		svc, err := newSupervised(cfg, cache, name, runtimes, stderr)
+		if err != nil {
+			return exitUserError, err
+		}
+		svc.Stdout, svc.Stderr, svc.Log = stdout, stderr, stderr
+		supervised = append(supervised, svc)
+	}
+	// a cache that cannot be written, e.g. without root, is not an error
+	_ = cache.Save()
+	group, err := supervisor.NewGroup(supervised...)
+	if err != nil {
+		return exitUserError, err
+	}
+
+	var l net.Listener
+	var server *api.Server
+	if listenAddr != "" {
+		if l, err = api.Listen(listenAddr); err != nil {
+			return exitUserError, fmt.Errorf("cannot listen on %s: %w", listenAddr, err)
+		}
+		server = api.New(cfg, group.Services()...)
+		server.SetCommandHold(group.HoldReaper)
+	}
+	ctx, stop := group.HandleSignals(context.Background(), func() {
+		reloadServices(cfgPath, group, runtimes, noCache, server, stderr)
+	})
+	defer stop()
+
+	if err = group.Start(); err != nil {
+		if l != nil {
+			l.Close()
+		}
+		return exitUserError, err
+	}
+	// Notify systemd daemon that all services have started
+	status := fmt.Sprintf("STATUS=%d service(s) running", len(supervised))
+	if _, err = daemon.SdNotify(false, daemon.SdNotifyReady+"\n"+status); err != nil {
 		fmt.Fprintf(os.Stderr, "systemd notification failed: %v\n", err)
 	}
-	// TODO: Replace this with an actual supervisor loop
-	if err = process.Wait(); err != nil {
-		return err
+
+	var served chan error
+	cancelServe := func() {}
+	if l != nil {
+		var serveCtx context.Context
+		serveCtx, cancelServe = context.WithCancel(context.Background())
+		served = make(chan error, 1)
+		go func() {
+			served <- server.Serve(serveCtx, l)
+		}()
+	}
+	err = group.Run(ctx)
+	cancelServe()
+	if served != nil {
+		if serveErr := <-served; serveErr != nil && err == nil {
+			err = serveErr
+		}
+	}
+	code := group.ExitCode()
+	if err != nil && code == exitOK {
+		code = exitUserError
+	}
+	return code, err
+}
+
+// reloadServices loads the config at cfgPath again and swaps it into the services
+// of group and into server, when not nil, without restarting the services, see
+// supervisor.Service.Reload. An invalid config is reported and the current one kept.
+func reloadServices(
+	cfgPath string,
+	group *supervisor.Group,
+	runtimes []string,
+	noCache bool,
+	server *api.Server,
+	stderr io.Writer,
+) {
+	// version probes and package queries must not be collected by the reaper
+	release := group.HoldReaper()
+	defer release()
+	err := func() error {
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return err
+		}
+		cache := openCache(cfg, noCache)
+		next := make([]*supervisor.Service, 0, len(group.Services()))
+		for _, svc := range group.Services() {
+			var n *supervisor.Service
+			if n, err = newSupervised(cfg, cache, svc.Name, runtimes, stderr); err != nil {
+				return err
+			}
+			next = append(next, n)
+		}
+		_ = cache.Save()
+		if err = group.Reload(next...); err != nil {
+			return err
+		}
+		if server != nil {
+			server.SetConfig(cfg)
+		}
+		return nil
+	}()
+	if err != nil {
+		fmt.Fprintf(stderr, "reload config %q failed, keeping the current one: %v\n", cfgPath, err)
 	}
-	return nil
 }
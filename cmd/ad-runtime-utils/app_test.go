@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"debug/elf"
+	"encoding/binary"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
 )
 
 func TestRun_MissingRuntime(t *testing.T) {
@@ -60,6 +65,54 @@ services:
 	}
 }
 
+func TestRun_Cache(t *testing.T) {
+	base := t.TempDir()
+	javaDir := filepath.Join(base, "jdk17")
+	os.MkdirAll(filepath.Join(javaDir, "bin"), 0o755)
+	os.WriteFile(filepath.Join(javaDir, "bin", "java"), []byte{}, 0o755)
+	cacheFile := filepath.Join(base, "run", "cache.json")
+
+	cfg := `
+bigtop_utils:
+  disabled: true
+cache:
+  enabled: true
+  path: ` + cacheFile + `
+default:
+  runtimes:
+    java:
+      version: "17"
+      paths: ["` + filepath.Join(base, "jdk") + `"]
+services:
+  kafka:
+    health_checks:
+      - type: port
+        params: {port: 9092}
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	if code := Run([]string{"--config", cfgFile, "--runtime", "java", "--no-cache"}, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Errorf("cache written with --no-cache: %v", err)
+	}
+	for range 2 {
+		out.Reset()
+		if code := Run([]string{"--config", cfgFile, "--runtime", "java"}, &out, &errb); code != exitOK {
+			t.Fatalf("exit=%d stderr=%q", code, errb.String())
+		}
+		if want := "export JAVA_HOME=" + javaDir + "\n"; out.String() != want {
+			t.Errorf("stdout = %q; want %q", out.String(), want)
+		}
+	}
+	if data, err := os.ReadFile(cacheFile); err != nil || !strings.Contains(string(data), javaDir) {
+		t.Errorf("cache = %q, %v", data, err)
+	}
+}
+
 func TestRun_ListAll(t *testing.T) {
 	base := t.TempDir()
 	javaDir := filepath.Join(base, "jdk8")
@@ -263,3 +316,385 @@ default:
 		t.Fatalf("expected empty stderr, got %q", errb.String())
 	}
 }
+
+func TestRun_DetectJavaLibs(t *testing.T) {
+	t.Setenv("JAVA_NATIVE_PATH", "")
+	t.Setenv("JAVA_NATIVE_LIBS", "")
+	base := t.TempDir()
+	javaHome := filepath.Join(base, "jdk17")
+	os.MkdirAll(filepath.Join(javaHome, "bin"), 0o755)
+	os.WriteFile(filepath.Join(javaHome, "bin", "java"), []byte{}, 0o755)
+
+	cfg := `
+default:
+  runtimes:
+    java:
+      version: "17"
+      override_path: "` + javaHome + `"
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+	args := []string{"--config", cfgFile, "--detect-javalibs"}
+
+	var out, errb bytes.Buffer
+	if code := Run(args, &out, &errb); code != exitNotFound {
+		t.Fatalf("exit=%d want=%d; stderr=%q", code, exitNotFound, errb.String())
+	}
+
+	server := filepath.Join(javaHome, "lib", "server")
+	os.MkdirAll(server, 0o755)
+	os.WriteFile(filepath.Join(server, "libjvm.so"), []byte{}, 0o644)
+
+	out.Reset()
+	errb.Reset()
+	if code := Run(args, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	want := "export JAVA_NATIVE_PATH=" + server + "\n"
+	if out.String() != want {
+		t.Errorf("stdout = %q; want %q", out.String(), want)
+	}
+}
+
+func TestRun_ClasspathWithRuntime(t *testing.T) {
+	t.Setenv("BIGTOP_CLASSPATH", "")
+	base := t.TempDir()
+	javaHome := filepath.Join(base, "jdk17")
+	os.MkdirAll(filepath.Join(javaHome, "bin"), 0o755)
+	os.WriteFile(filepath.Join(javaHome, "bin", "java"), []byte{}, 0o755)
+	libDir := filepath.Join(base, "lib")
+	os.MkdirAll(libDir, 0o755)
+	os.WriteFile(filepath.Join(libDir, "b-1.0.jar"), []byte{}, 0o644)
+	os.WriteFile(filepath.Join(libDir, "a-1.0.jar"), []byte{}, 0o644)
+
+	cfg := `
+services:
+  svc:
+    runtimes:
+      java:
+        version: "17"
+        override_path: "` + javaHome + `"
+    classpath:
+      dirs:
+        - "` + libDir + `"
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	args := []string{"--config", cfgFile, "--service", "svc", "--runtime", "java", "--classpath"}
+	var out, errb bytes.Buffer
+	if code := Run(args, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	want := "export JAVA_HOME=" + javaHome + "\n" +
+		"export BIGTOP_CLASSPATH=" + filepath.Join(libDir, "a-1.0.jar") + ":" +
+		filepath.Join(libDir, "b-1.0.jar") + "\n"
+	if out.String() != want {
+		t.Errorf("stdout = %q; want %q", out.String(), want)
+	}
+}
+
+func TestRun_ListBigtopDefaults(t *testing.T) {
+	base := t.TempDir()
+	javaDir := filepath.Join(base, "jdk8")
+	os.MkdirAll(filepath.Join(javaDir, "bin"), 0o755)
+	os.WriteFile(filepath.Join(javaDir, "bin", "java"), []byte{}, 0o755)
+	defaults := filepath.Join(base, "bigtop-utils")
+	os.WriteFile(defaults, []byte("export JAVA_HOME="+javaDir+"\n"), 0o644)
+
+	cfg := `
+bigtop_utils:
+  defaults_file: "` + defaults + `"
+default:
+  runtimes:
+    java:
+      version: "8"
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	if code := Run([]string{"--config", cfgFile, "--list"}, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	got := out.String()
+	if !strings.Contains(got, "Bigtop defaults ("+defaults+"):\n  JAVA_HOME="+javaDir) {
+		t.Errorf("missing bigtop defaults section, got:\n%s", got)
+	}
+	if !strings.Contains(got, "java: "+javaDir+" (from "+defaults+")") {
+		t.Errorf("missing bigtop source for java, got:\n%s", got)
+	}
+}
+
+func TestRun_ListProbeVersions(t *testing.T) {
+	base := t.TempDir()
+	toolDir := filepath.Join(base, "tool")
+	os.MkdirAll(filepath.Join(toolDir, "bin"), 0o755)
+	os.WriteFile(filepath.Join(toolDir, "bin", "tool"), []byte("#!/bin/sh\necho tool 1.2.3\n"), 0o755)
+	cfg := `
+bigtop_utils:
+  disabled: true
+runtime_definitions:
+  tool:
+    version_probe:
+      args: [--version]
+      regex: 'tool (\S+)'
+default:
+  runtimes:
+    tool:
+      version: "1"
+      override_path: "` + toolDir + `"
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	if code := Run([]string{"--config", cfgFile, "--list"}, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	if !strings.Contains(out.String(), "  tool: "+toolDir+"\n") {
+		t.Errorf("--list probed the version, got:\n%s", out.String())
+	}
+	out.Reset()
+	if code := Run([]string{"--config", cfgFile, "--list", "--probe-versions"}, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	if !strings.Contains(out.String(), "  tool: "+toolDir+" (version 1.2.3)\n") {
+		t.Errorf("missing probed version, got:\n%s", out.String())
+	}
+}
+
+func TestRun_ListSkippedCandidates(t *testing.T) {
+	base := t.TempDir()
+	// an ELF header of another architecture than the host
+	machine, arch := elf.EM_AARCH64, "arm64"
+	if runtime.GOARCH == "arm64" {
+		machine, arch = elf.EM_X86_64, "amd64"
+	}
+	hdr := elf.Header64{Machine: uint16(machine), Version: uint32(elf.EV_CURRENT), Ehsize: 64}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS], hdr.Ident[elf.EI_DATA] = byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	var header bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, hdr)
+
+	foreign := filepath.Join(base, "jdk17-foreign")
+	javaDir := filepath.Join(base, "jdk17")
+	for _, dir := range []string{foreign, javaDir} {
+		os.MkdirAll(filepath.Join(dir, "bin"), 0o755)
+	}
+	os.WriteFile(filepath.Join(foreign, "bin", "java"), header.Bytes(), 0o755)
+	os.WriteFile(filepath.Join(javaDir, "bin", "java"), []byte{}, 0o755)
+
+	cfg := `
+bigtop_utils:
+  disabled: true
+default:
+  runtimes:
+    java:
+      version: "17"
+      paths: ["` + foreign + `", "` + javaDir + `"]
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	if code := Run([]string{"--config", cfgFile, "--list"}, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	want := "  java: " + javaDir + "\n    skipped " + foreign + ": " + arch + " binary on " + runtime.GOARCH + " host\n"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("missing skipped candidate, got:\n%s", out.String())
+	}
+}
+
+func TestRun_ServeStartRequiresSupervise(t *testing.T) {
+	base := t.TempDir()
+	javaDir := filepath.Join(base, "jdk")
+	os.MkdirAll(filepath.Join(javaDir, "bin"), 0o755)
+	os.WriteFile(filepath.Join(javaDir, "bin", "java"), []byte{}, 0o755)
+	cfg := `
+bigtop_utils:
+  disabled: true
+services:
+  svc:
+    executable: /bin/true
+    runtimes:
+      java:
+        override_path: "` + javaDir + `"
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	args := []string{"--config", cfgFile, "--service", "svc", "--runtime", "java", "--start", "--serve"}
+	code := Run(args, &out, &errb)
+	if code != exitUserError || !strings.Contains(errb.String(), "requires --supervise") {
+		t.Errorf("exit=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestRun_StartServices(t *testing.T) {
+	base := t.TempDir()
+	marker := filepath.Join(base, "started")
+	cfg := `
+bigtop_utils:
+  disabled: true
+services:
+  app:
+    executable: /bin/sh
+    executable_args: ["-c", "echo app >> ` + marker + `"]
+    requires: [db]
+  db:
+    executable: /bin/sh
+    executable_args: ["-c", "echo db >> ` + marker + `; sleep 0.5"]
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	if code := Run([]string{"--config", cfgFile, "--start", "--services", "app"}, &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	// db is started because app requires it
+	got, _ := os.ReadFile(marker)
+	if !strings.Contains(string(got), "db\n") || !strings.Contains(string(got), "app\n") {
+		t.Errorf("started = %q, want app and db", got)
+	}
+}
+
+func TestRun_SuperviseExitStatus(t *testing.T) {
+	base := t.TempDir()
+	cfg := `
+bigtop_utils:
+  disabled: true
+services:
+  failing:
+    executable: /bin/sh
+    executable_args: ["-c", "exit 7"]
+`
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	os.WriteFile(cfgFile, []byte(cfg), 0o644)
+
+	var out, errb bytes.Buffer
+	if code := Run([]string{"--config", cfgFile, "--start", "--services", "failing"}, &out, &errb); code != 7 {
+		t.Errorf("exit=%d, want the service exit status 7; stderr=%q", code, errb.String())
+	}
+}
+
+func TestNewSupervised_TrustStore(t *testing.T) {
+	base := t.TempDir()
+	javaHome := filepath.Join(base, "jdk17")
+	cacerts := filepath.Join(javaHome, "lib", "security", "cacerts")
+	bareJava := filepath.Join(base, "jdk-bare")
+	files := []string{cacerts, filepath.Join(javaHome, "bin", "java"), filepath.Join(bareJava, "bin", "java")}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(file, nil, 0o755); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	cfgFile := filepath.Join(base, "cfg.yaml")
+	yaml := `
+services:
+  plain:
+    executable: /bin/true
+    runtimes:
+      java:
+        override_path: "` + bareJava + `"
+  secure:
+    executable: /bin/true
+    runtimes:
+      java:
+        override_path: "` + javaHome + `"
+    health_checks:
+      - type: tls
+        params:
+          port: 8443
+          verify: true
+`
+	if err := os.WriteFile(cfgFile, []byte(yaml), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// no tls check: the cacerts are not looked up and their absence is not reported
+	var errb bytes.Buffer
+	svc, err := newSupervised(cfg, nil, "plain", nil, &errb)
+	if err != nil {
+		t.Fatalf("newSupervised: %v", err)
+	}
+	if svc.TrustStore != "" || errb.Len() != 0 {
+		t.Errorf("plain truststore = %q, stderr %q, want no lookup", svc.TrustStore, errb.String())
+	}
+	if svc, err = newSupervised(cfg, nil, "secure", nil, &errb); err != nil {
+		t.Fatalf("newSupervised: %v", err)
+	}
+	if svc.TrustStore != cacerts {
+		t.Errorf("secure truststore = %q, want %q", svc.TrustStore, cacerts)
+	}
+}
+
+func TestRun_StartHooks(t *testing.T) {
+	base := t.TempDir()
+	javaHome := filepath.Join(base, "jdk")
+	if err := os.MkdirAll(filepath.Join(javaHome, "bin"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(javaHome, "bin", "java"), nil, 0o755); err != nil {
+		t.Fatalf("write java: %v", err)
+	}
+	hookEnv, svcEnv := filepath.Join(base, "hook.env"), filepath.Join(base, "svc.env")
+	writeCfg := func(onFailure string) string {
+		cfg := `
+bigtop_utils:
+  disabled: true
+services:
+  kafka:
+    executable: /bin/sh
+    executable_args: ["-c", "env | sort > ` + svcEnv + `"]
+    env_vars:
+      KAFKA_HEAP_OPTS: -Xmx1G
+    runtimes:
+      java:
+        override_path: "` + javaHome + `"
+    pre_start:
+      - command: ["/bin/sh", "-c", "env | sort > ` + hookEnv + `"]
+        on_failure: ` + onFailure + `
+`
+		cfgFile := filepath.Join(base, "cfg.yaml")
+		if err := os.WriteFile(cfgFile, []byte(cfg), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		return cfgFile
+	}
+	args := func(cfgFile string) []string {
+		return []string{"--config", cfgFile, "--service", "kafka", "--runtime", "java", "--start"}
+	}
+
+	// hooks are validated without supervision as well
+	var out, errb bytes.Buffer
+	if code := Run(args(writeCfg("warnn")), &out, &errb); code != exitUserError ||
+		!strings.Contains(errb.String(), `unknown hook on_failure "warnn"`) {
+		t.Errorf("exit=%d stderr=%q, want the on_failure typo rejected", code, errb.String())
+	}
+	if _, err := os.Stat(hookEnv); !os.IsNotExist(err) {
+		t.Errorf("hook ran with an invalid config: %v", err)
+	}
+
+	// hooks get the environment of the service
+	errb.Reset()
+	if code := Run(args(writeCfg("warn")), &out, &errb); code != exitOK {
+		t.Fatalf("exit=%d stderr=%q", code, errb.String())
+	}
+	hook, _ := os.ReadFile(hookEnv)
+	svc, _ := os.ReadFile(svcEnv)
+	if string(hook) != string(svc) || !strings.Contains(string(svc), "JAVA_HOME="+javaHome+"\n") {
+		t.Errorf("hook env:\n%s\nservice env:\n%s", hook, svc)
+	}
+}
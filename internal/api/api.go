@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/detect"
+	"github.com/arenadata/ad-runtime-utils/internal/supervisor"
+)
+
+const (
+	DefaultListen     = "127.0.0.1:8765"
+	unixPrefix        = "unix:"
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 10 * time.Second
+	// healthRequestTimeout bounds the wait of a health request for the checks,
+	// which poll until their own timeout, a minute by default, when failing.
+	healthRequestTimeout = 5 * time.Second
+)
+
+// RuntimeInfo is the resolution of a runtime for a service (or the default one).
+type RuntimeInfo struct {
+	Service string   `json:"service,omitempty"`
+	Runtime string   `json:"runtime"`
+	Path    string   `json:"path,omitempty"`
+	Source  string   `json:"source,omitempty"`
+	Exports []string `json:"exports,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// RuntimeList is the response of GET /v1/runtimes.
+type RuntimeList struct {
+	Default  []RuntimeInfo            `json:"default"`
+	Services map[string][]RuntimeInfo `json:"services"`
+}
+
+// CACertsInfo is the response of the cacerts endpoints.
+type CACertsInfo struct {
+	Service  string `json:"service,omitempty"`
+	JavaHome string `json:"java_home"`
+	CACerts  string `json:"cacerts"`
+}
+
+// HealthInfo is the response of the health endpoint.
+// Pending is set when the checks did not complete within the request and
+// Checks are the results of the last completed run.
+type HealthInfo struct {
+	Service string                   `json:"service"`
+	Healthy bool                     `json:"healthy"`
+	Pending bool                     `json:"pending,omitempty"`
+	Checks  []supervisor.CheckResult `json:"checks"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes runtime detection and the state of supervised services over HTTP.
+type Server struct {
+	cfg      atomic.Pointer[config.Config]
+	services map[string]*supervisor.Service
+	mux      *http.ServeMux
+	hold     func() (release func())
+}
+
+// New returns a Server answering from cfg and reporting the given supervised services.
+func New(cfg *config.Config, services ...*supervisor.Service) *Server {
+	s := &Server{
+		services: make(map[string]*supervisor.Service, len(services)),
+		mux:      http.NewServeMux(),
+	}
+	s.cfg.Store(cfg)
+	for _, svc := range services {
+		s.services[svc.Name] = svc
+	}
+	s.mux.HandleFunc("GET /v1/runtimes", s.runsCommands(s.handleListRuntimes))
+	s.mux.HandleFunc("GET /v1/runtimes/{runtime}", s.runsCommands(s.handleResolve))
+	s.mux.HandleFunc("GET /v1/services/{service}/runtimes/{runtime}", s.runsCommands(s.handleResolve))
+	s.mux.HandleFunc("GET /v1/cacerts", s.runsCommands(s.handleCACerts))
+	s.mux.HandleFunc("GET /v1/services/{service}/cacerts", s.runsCommands(s.handleCACerts))
+	s.mux.HandleFunc("GET /v1/services/{service}/health", s.handleHealth)
+	s.mux.HandleFunc("GET /v1/status", s.handleStatusList)
+	s.mux.HandleFunc("GET /v1/services/{service}/status", s.handleStatus)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return s
+}
+
+// SetCommandHold makes the server call hold around the requests that may run
+// commands, such as an rpm query during a resolution, and release it after.
+// Used with supervisor.Group.HoldReaper when the services run under PID 1.
+func (s *Server) SetCommandHold(hold func() (release func())) {
+	s.hold = hold
+}
+
+// runsCommands wraps a handler that may run commands with the hold set with SetCommandHold.
+func (s *Server) runsCommands(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.hold != nil {
+			release := s.hold()
+			defer release()
+		}
+		h(w, r)
+	}
+}
+
+// SetConfig makes the server answer from cfg, e.g. after a reload.
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.cfg.Store(cfg)
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Listen opens the listener for addr: "unix:/path/to.sock" for a Unix socket,
+// anything else is a TCP address. A stale Unix socket file is removed first, any
+// other file at the path is left alone and fails the listen.
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		info, err := os.Lstat(path)
+		switch {
+		case err == nil && info.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		case err == nil:
+			if err = os.Remove(path); err != nil {
+				return nil, err
+			}
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Serve serves HTTP requests on l until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{Handler: s.mux, ReadHeaderTimeout: readHeaderTimeout}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func resolve(cfg *config.Config, service, runtime string) RuntimeInfo {
+	info := RuntimeInfo{Service: service, Runtime: runtime}
+	res, err := detect.Resolve(cfg, service, runtime)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.Path = res.Path
+	info.Source = res.Source
+	info.Exports = detect.Exports(cfg, service, runtime, res.Path)
+	return info
+}
+
+func (s *Server) handleListRuntimes(w http.ResponseWriter, _ *http.Request) {
+	cfg := s.cfg.Load()
+	list := RuntimeList{Services: make(map[string][]RuntimeInfo)}
+	// runtimes are listed by name, so that the output is stable
+	for _, rt := range slices.Sorted(maps.Keys(cfg.Default.Runtimes)) {
+		list.Default = append(list.Default, resolve(cfg, "", rt))
+	}
+	for name, svc := range cfg.Services {
+		for _, rt := range slices.Sorted(maps.Keys(svc.Runtimes)) {
+			list.Services[name] = append(list.Services[name], resolve(cfg, name, rt))
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	service := r.PathValue("service")
+	if service != "" && !s.knownService(service) {
+		writeError(w, http.StatusNotFound, "service "+service+" not found in config")
+		return
+	}
+	info := resolve(s.cfg.Load(), service, r.PathValue("runtime"))
+	if info.Error != "" {
+		writeJSON(w, http.StatusNotFound, info)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleCACerts(w http.ResponseWriter, r *http.Request) {
+	service := r.PathValue("service")
+	if service != "" && !s.knownService(service) {
+		writeError(w, http.StatusNotFound, "service "+service+" not found in config")
+		return
+	}
+	javaHome, err := detect.ResolveRuntime(s.cfg.Load(), service, "java")
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	cacerts, err := detect.FindCACerts(javaHome, nil)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, CACertsInfo{Service: service, JavaHome: javaHome, CACerts: cacerts})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	svc, ok := s.services[r.PathValue("service")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "service "+r.PathValue("service")+" is not supervised")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), healthRequestTimeout)
+	defer cancel()
+	results, err := svc.CheckHealth(ctx)
+	if errors.Is(err, supervisor.ErrNotRunning) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	info := HealthInfo{Service: svc.Name, Healthy: err == nil, Checks: results}
+	if errors.Is(err, supervisor.ErrChecksRunning) {
+		info.Pending = true
+		info.Healthy = lastRunHealthy(results)
+	}
+	if !info.Healthy {
+		writeJSON(w, http.StatusServiceUnavailable, info)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// lastRunHealthy reports whether the results of the last completed run of the
+// health checks are all healthy. A run stops at the first failing check.
+func lastRunHealthy(results []supervisor.CheckResult) bool {
+	if len(results) == 0 {
+		return false
+	}
+	for _, res := range results {
+		if !res.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleStatusList(w http.ResponseWriter, _ *http.Request) {
+	statuses := make([]supervisor.Status, 0, len(s.services))
+	for _, svc := range s.services {
+		statuses = append(statuses, svc.Status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	svc, ok := s.services[r.PathValue("service")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "service "+r.PathValue("service")+" is not supervised")
+		return
+	}
+	writeJSON(w, http.StatusOK, svc.Status())
+}
+
+func (s *Server) knownService(name string) bool {
+	_, ok := s.cfg.Load().Services[name]
+	return ok
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, errorResponse{Error: msg})
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/supervisor"
+)
+
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	javaHome := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(javaHome, "bin"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(javaHome, "bin", "java"), nil, 0o755); err != nil {
+		t.Fatalf("write java: %v", err)
+	}
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"trino": {Runtimes: map[string]config.RuntimeSetting{"java": {OverridePath: javaHome}}},
+	}}
+	cfg.Default.Runtimes = map[string]config.RuntimeSetting{"java": {Version: "17", OverridePath: javaHome}}
+	return cfg
+}
+
+func get(t *testing.T, h http.Handler, path string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: decode %q: %v", path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestServer_Runtimes(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := New(cfg).Handler()
+
+	var list RuntimeList
+	if code := get(t, h, "/v1/runtimes", &list); code != http.StatusOK {
+		t.Fatalf("list code = %d", code)
+	}
+	if len(list.Default) != 1 || list.Default[0].Error != "" || len(list.Services["trino"]) != 1 {
+		t.Errorf("list = %+v", list)
+	}
+
+	var info RuntimeInfo
+	if code := get(t, h, "/v1/services/trino/runtimes/java", &info); code != http.StatusOK {
+		t.Fatalf("resolve code = %d", code)
+	}
+	if info.Path != cfg.Default.Runtimes["java"].OverridePath || info.Exports[0] != "JAVA_HOME="+info.Path {
+		t.Errorf("resolve = %+v", info)
+	}
+
+	if code := get(t, h, "/v1/runtimes/python", &info); code != http.StatusNotFound || info.Error == "" {
+		t.Errorf("unknown runtime: code = %d, info = %+v", code, info)
+	}
+	if code := get(t, h, "/v1/services/nope/runtimes/java", nil); code != http.StatusNotFound {
+		t.Errorf("unknown service: code = %d", code)
+	}
+}
+
+func TestServer_SetConfig(t *testing.T) {
+	cfg := newTestConfig(t)
+	s := New(cfg)
+	if code := get(t, s.Handler(), "/v1/services/presto/runtimes/java", nil); code != http.StatusNotFound {
+		t.Errorf("code before reload = %d", code)
+	}
+	next := newTestConfig(t)
+	next.Services["presto"] = next.Services["trino"]
+	s.SetConfig(next)
+	if code := get(t, s.Handler(), "/v1/services/presto/runtimes/java", nil); code != http.StatusOK {
+		t.Errorf("code after reload = %d", code)
+	}
+}
+
+func TestServer_SupervisedService(t *testing.T) {
+	cfg := newTestConfig(t)
+	sleeper := config.ServiceConfig{Executable: "/bin/sleep", ExecutableArgs: []string{"30"}}
+	svc := supervisor.New("sleeper", sleeper, nil)
+	h := New(cfg, svc).Handler()
+
+	if code := get(t, h, "/v1/services/sleeper/health", nil); code != http.StatusConflict {
+		t.Errorf("health before start: code = %d, want 409", code)
+	}
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = svc.Stop()
+		_ = svc.Wait()
+	})
+
+	var health HealthInfo
+	if code := get(t, h, "/v1/services/sleeper/health", &health); code != http.StatusOK || !health.Healthy {
+		t.Errorf("health: code = %d, info = %+v", code, health)
+	}
+	var statuses []supervisor.Status
+	if code := get(t, h, "/v1/status", &statuses); code != http.StatusOK || len(statuses) != 1 ||
+		statuses[0].State != supervisor.StateRunning {
+		t.Errorf("status: code = %d, statuses = %+v", code, statuses)
+	}
+	if code := get(t, h, "/v1/services/trino/status", nil); code != http.StatusNotFound {
+		t.Errorf("status of unsupervised service: code = %d", code)
+	}
+}
+
+func TestLastRunHealthy(t *testing.T) {
+	ok := supervisor.CheckResult{Type: "file", Healthy: true}
+	failed := supervisor.CheckResult{Type: "port", Error: "not listening"}
+	if lastRunHealthy(nil) || !lastRunHealthy([]supervisor.CheckResult{ok}) ||
+		lastRunHealthy([]supervisor.CheckResult{ok, failed}) {
+		t.Error("lastRunHealthy")
+	}
+}
+
+func TestServer_RuntimesSorted(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Default.Runtimes["python"] = config.RuntimeSetting{Version: "3.11"}
+	cfg.Default.Runtimes["go"] = config.RuntimeSetting{Version: "1.22"}
+	h := New(cfg).Handler()
+	for range 5 {
+		var list RuntimeList
+		get(t, h, "/v1/runtimes", &list)
+		var names []string
+		for _, info := range list.Default {
+			names = append(names, info.Runtime)
+		}
+		if !slices.Equal(names, []string{"go", "java", "python"}) {
+			t.Fatalf("default runtimes = %v, want them sorted by name", names)
+		}
+	}
+}
+
+func TestServe_UnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	// any other file at the path is kept, e.g. on a typo in --listen
+	cfgFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfgFile, []byte("services: {}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Listen("unix:" + cfgFile); err == nil {
+		t.Error("expected error for a regular file")
+	}
+	if _, err := os.Stat(cfgFile); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+
+	// a stale socket file from a previous run is replaced
+	sock := filepath.Join(dir, "api.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	l, err := Listen("unix:" + sock)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- New(newTestConfig(t)).Serve(ctx, l) }()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://api/v1/runtimes/java")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("code = %d", resp.StatusCode)
+	}
+
+	cancel()
+	if err = <-done; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/supervisor"
+)
+
+const (
+	metricsPrefix      = "ad_runtime_utils_"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// metric is a metric family in the Prometheus text exposition format.
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+func (m *metric) add(value float64, labels ...[2]string) {
+	m.samples = append(m.samples, sample{labels: labels, value: value})
+}
+
+func (m *metric) write(w io.Writer) {
+	if len(m.samples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s%s %s\n", metricsPrefix, m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s%s %s\n", metricsPrefix, m.name, m.typ)
+	for _, s := range m.samples {
+		fmt.Fprintf(w, "%s%s%s %s\n", metricsPrefix, m.name, formatLabels(s.labels), formatValue(s.value))
+	}
+}
+
+func label(name, value string) [2]string {
+	return [2]string{name, value}
+}
+
+func formatLabels(labels [][2]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels))
+	for _, l := range labels {
+		parts = append(parts, l[0]+`="`+escapeLabelValue(l[1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(v)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// handleMetrics reports the state of the supervised services in the Prometheus text format.
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	up := metric{name: "service_up", help: "Whether the service process is running.", typ: "gauge"}
+	uptime := metric{
+		name: "service_uptime_seconds",
+		help: "Seconds since the service process was started.",
+		typ:  "gauge",
+	}
+	restarts := metric{
+		name: "service_restarts_total",
+		help: "Number of times the service was restarted.",
+		typ:  "counter",
+	}
+	exitCode := metric{name: "service_last_exit_code", help: "Exit code of the last service process.", typ: "gauge"}
+	checkUp := metric{
+		name: "health_check_success",
+		help: "Whether the last run of the health check succeeded.",
+		typ:  "gauge",
+	}
+	checkDuration := metric{
+		name: "health_check_duration_seconds",
+		help: "Duration of the last run of the health check.",
+		typ:  "gauge",
+	}
+	runtimeInfo := metric{name: "runtime_info", help: "Runtime detected for the service.", typ: "gauge"}
+
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	for _, name := range names {
+		st := s.services[name].Status()
+		svc := label("service", name)
+
+		running := 0.0
+		if st.State == supervisor.StateRunning {
+			running = 1
+		}
+		up.add(running, svc)
+		if st.PID != 0 && !st.StartedAt.IsZero() {
+			uptime.add(now.Sub(st.StartedAt).Seconds(), svc)
+		}
+		restarts.add(float64(st.Restarts), svc)
+		if st.LastExitCode != nil {
+			exitCode.add(float64(*st.LastExitCode), svc)
+		}
+		for i, c := range st.Checks {
+			labels := [][2]string{svc, label("check", c.Type), label("index", strconv.Itoa(i))}
+			healthy := 0.0
+			if c.Healthy {
+				healthy = 1
+			}
+			checkUp.add(healthy, labels...)
+			checkDuration.add(c.Duration.Seconds(), labels...)
+		}
+		for _, rt := range st.Runtimes {
+			runtimeInfo.add(1, svc, label("runtime", rt.Name), label("version", rt.Version), label("path", rt.Path))
+		}
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	for _, m := range []*metric{&up, &uptime, &restarts, &exitCode, &checkUp, &checkDuration, &runtimeInfo} {
+		m.write(w)
+	}
+}
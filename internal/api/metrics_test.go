@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/supervisor"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	sleeper := config.ServiceConfig{Executable: "/bin/sleep", ExecutableArgs: []string{"30"}}
+	svc := supervisor.New("sleeper", sleeper, nil)
+	svc.Runtimes = []supervisor.Runtime{{Name: "java", Path: `/opt/"jdk"`, Version: "17.0.9"}}
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = svc.Stop()
+		_ = svc.Wait()
+	})
+
+	rec := httptest.NewRecorder()
+	New(&config.Config{}, svc).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("code = %d, content type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE ad_runtime_utils_service_up gauge\nad_runtime_utils_service_up{service=\"sleeper\"} 1\n",
+		"ad_runtime_utils_service_restarts_total{service=\"sleeper\"} 0\n",
+		"ad_runtime_utils_service_uptime_seconds{service=\"sleeper\"} ",
+		`ad_runtime_utils_runtime_info{service="sleeper",runtime="java",version="17.0.9",path="/opt/\"jdk\""} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "last_exit_code") {
+		t.Errorf("exit code reported for a running service:\n%s", body)
+	}
+}
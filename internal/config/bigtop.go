@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const DefaultBigtopDefaultsFile = "/etc/default/bigtop-utils"
+
+// envAssignRe matches "KEY=VALUE" and "export KEY=VALUE" lines.
+var envAssignRe = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// BigtopDefaultsPath returns the bigtop-utils defaults file to read, or "" if disabled.
+func (c *Config) BigtopDefaultsPath() string {
+	if c.BigtopUtils.Disabled {
+		return ""
+	}
+	if c.BigtopUtils.DefaultsFile != "" {
+		return c.BigtopUtils.DefaultsFile
+	}
+	return DefaultBigtopDefaultsFile
+}
+
+func loadBigtopDefaults(cfg *Config) error {
+	path := cfg.BigtopDefaultsPath()
+	if path == "" {
+		return nil
+	}
+	vars, err := ParseEnvFile(path)
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			return nil
+		}
+		return fmt.Errorf("read bigtop-utils defaults %q: %w", path, err)
+	}
+	cfg.BigtopDefaults = vars
+	return nil
+}
+
+// ParseEnvFile reads a shell file of variable assignments such as /etc/default/bigtop-utils.
+// Only plain "KEY=VALUE" and "export KEY=VALUE" lines are taken into account, everything
+// else (comments, bare "export KEY", commands) is ignored. Values may be quoted;
+// $VAR and ${VAR} in unquoted and double-quoted values are expanded using the
+// variables assigned earlier in the file and then the process environment.
+func ParseEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars := make(map[string]string)
+	lookup := func(name string) string {
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return os.Getenv(name)
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := envAssignRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		vars[m[1]] = unquoteEnvValue(m[2], lookup)
+	}
+	return vars, scanner.Err()
+}
+
+func unquoteEnvValue(raw string, lookup func(string) string) string {
+	raw = strings.TrimSpace(raw)
+	if len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'' {
+		return raw[1 : len(raw)-1]
+	}
+	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		return os.Expand(raw[1:len(raw)-1], lookup)
+	}
+	// strip trailing comments of unquoted values
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return os.Expand(raw, lookup)
+}
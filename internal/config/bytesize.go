@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size read from YAML either as a number of bytes or as a number
+// with a K, M or G suffix (powers of 1024), e.g. "100M" or "1GB".
+type ByteSize int64
+
+// UnmarshalYAML implements the goccy/go-yaml InterfaceUnmarshaler.
+func (b *ByteSize) UnmarshalYAML(unmarshal func(any) error) error {
+	var raw any
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case uint64:
+		*b = ByteSize(v)
+	case int64:
+		*b = ByteSize(v)
+	case int:
+		*b = ByteSize(v)
+	case string:
+		size, err := parseByteSize(v)
+		if err != nil {
+			return err
+		}
+		*b = size
+	default:
+		return fmt.Errorf("invalid size %v", raw)
+	}
+	return nil
+}
+
+func parseByteSize(s string) (ByteSize, error) {
+	num := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	multiplier := int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if trimmed, ok := strings.CutSuffix(num, suffix); ok {
+			num = trimmed
+			multiplier = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(n * multiplier), nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestByteSize_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ByteSize
+	}{
+		{`max_size: 4096`, 4096},
+		{`max_size: 10K`, 10 << 10},
+		{`max_size: "100MB"`, 100 << 20},
+		{`max_size: 1g`, 1 << 30},
+	}
+	for _, tt := range tests {
+		var out OutputConfig
+		if err := yaml.Unmarshal([]byte(tt.in), &out); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if out.MaxSize != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.in, out.MaxSize, tt.want)
+		}
+	}
+
+	var out OutputConfig
+	if err := yaml.Unmarshal([]byte(`max_size: 10T`), &out); err == nil {
+		t.Error("expected error for unknown suffix")
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -9,15 +10,109 @@ import (
 )
 
 type RuntimeSetting struct {
-	Version      string   `yaml:"version"`
-	OverridePath string   `yaml:"override_path,omitempty"`
-	EnvVar       string   `yaml:"env_var,omitempty"`
-	Paths        []string `yaml:"paths,omitempty"`
+	Version      string      `yaml:"version"`
+	OverridePath string      `yaml:"override_path,omitempty"`
+	EnvVar       string      `yaml:"env_var,omitempty"`
+	Paths        []string    `yaml:"paths,omitempty"`
+	NativeLibs   []string    `yaml:"native_libs,omitempty"`
+	NativePath   string      `yaml:"native_path,omitempty"`
+	Venv         *VenvConfig `yaml:"venv,omitempty"`
+	// Strategies are tried in order to detect the runtime, DefaultStrategies when empty.
+	Strategies []Strategy `yaml:"strategies,omitempty"`
+	// Alternatives are the names of the links in the alternatives system used by
+	// the alternatives strategy, the names of the runtime executables when empty.
+	Alternatives []string `yaml:"alternatives,omitempty"`
 }
 
-type HealthCheckConfig struct {
-	Type   string         `yaml:"type"`
-	Params map[string]any `yaml:"params,omitempty"`
+// VenvConfig describes a python virtualenv managed with --ensure.
+// BaseVersion selects the base interpreter through autodetect.runtimes.python.<version>.
+type VenvConfig struct {
+	Path         string `yaml:"path"`
+	BaseVersion  string `yaml:"base_version"`
+	Requirements string `yaml:"requirements,omitempty"`
+}
+
+// RuntimeDefinition describes how a runtime installation is recognised and exported.
+// Executables are looked up in <home>/<bin_dir>; the first existing one makes the
+// directory a valid installation. EnvVars are the variables the installation
+// directory is exported as, Exports are additional variables where ${home}
+// is replaced by the installation directory.
+type RuntimeDefinition struct {
+	Executables  []string          `yaml:"executables,omitempty"`
+	BinDir       string            `yaml:"bin_dir,omitempty"`
+	EnvVars      []string          `yaml:"env_vars,omitempty"`
+	Exports      map[string]string `yaml:"exports,omitempty"`
+	VersionProbe *VersionProbe     `yaml:"version_probe,omitempty"`
+}
+
+// VersionProbe runs the runtime executable with Args and extracts the version
+// from its output with the first capture group of Regex.
+type VersionProbe struct {
+	Args  []string `yaml:"args,omitempty"`
+	Regex string   `yaml:"regex"`
+}
+
+// ClasspathConfig describes how to assemble a Java classpath from jar directories.
+type ClasspathConfig struct {
+	Dirs             []string `yaml:"dirs,omitempty"`
+	Include          []string `yaml:"include,omitempty"`
+	Exclude          []string `yaml:"exclude,omitempty"`
+	EnvVar           string   `yaml:"env_var,omitempty"`
+	UseEnvClasspath  bool     `yaml:"use_env_classpath,omitempty"`
+	FailOnDuplicates bool     `yaml:"fail_on_duplicates,omitempty"`
+}
+
+// Restart policies of a supervised service.
+const (
+	RestartNo        = "no"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicy describes when a supervised service is restarted after its process exits.
+// MaxRestarts of 0 means no limit.
+type RestartPolicy struct {
+	Policy      string   `yaml:"policy"`
+	MaxRestarts int      `yaml:"max_restarts,omitempty"`
+	Delay       Duration `yaml:"delay,omitempty"`
+}
+
+// Failure modes of a service hook.
+const (
+	HookOnFailureAbort = "abort"
+	HookOnFailureWarn  = "warn"
+)
+
+// HookConfig is a command run before or after the service process with the same
+// environment. A hook whose Creates path exists is skipped, which makes one-off
+// commands such as formatting storage idempotent. OnFailure is "abort" (default)
+// or "warn".
+type HookConfig struct {
+	Command   []string `yaml:"command"`
+	Timeout   Duration `yaml:"timeout,omitempty"`
+	OnFailure string   `yaml:"on_failure,omitempty"`
+	Creates   string   `yaml:"creates,omitempty"`
+}
+
+// Output modes of a service.
+const (
+	OutputInherit = "inherit"
+	OutputFile    = "file"
+	OutputBoth    = "both"
+)
+
+// OutputConfig routes the stdout and stderr of a service: inherited from
+// ad-runtime-utils (journald under systemd, the default), written to File or both.
+// File is rotated once it grows over MaxSize or gets older than MaxAge, MaxFiles
+// rotated files are kept. Lines are optionally prefixed with a timestamp and the stream name.
+type OutputConfig struct {
+	Mode        string   `yaml:"mode,omitempty"`
+	File        string   `yaml:"file,omitempty"`
+	MaxSize     ByteSize `yaml:"max_size,omitempty"`
+	MaxAge      Duration `yaml:"max_age,omitempty"`
+	MaxFiles    int      `yaml:"max_files,omitempty"`
+	Timestamps  bool     `yaml:"timestamps,omitempty"`
+	StreamNames bool     `yaml:"stream_names,omitempty"`
 }
 
 type ServiceConfig struct {
@@ -28,11 +123,32 @@ type ServiceConfig struct {
 	EnvVars        map[string]string         `yaml:"env_vars,omitempty"`
 	EnvVarsFile    string                    `yaml:"env_vars_file,omitempty"`
 	HealthChecks   []HealthCheckConfig       `yaml:"health_checks,omitempty"`
+	Classpath      *ClasspathConfig          `yaml:"classpath,omitempty"`
+	// After lists services started before this one when supervised together,
+	// Requires those that must be running for this one to run.
+	After    []string       `yaml:"after,omitempty"`
+	Requires []string       `yaml:"requires,omitempty"`
+	Restart  *RestartPolicy `yaml:"restart,omitempty"`
+	// RestartOnChange restarts a supervised service when a configuration reload
+	// changes its executable, arguments, environment or runtimes.
+	RestartOnChange bool `yaml:"restart_on_change,omitempty"`
+	// StopTimeout is how long a supervised service may take to exit after SIGTERM
+	// before it is killed.
+	StopTimeout Duration `yaml:"stop_timeout,omitempty"`
+	// PreStart hooks run before the process is started, PostStart once it passed
+	// its health checks, PostStop after it exited.
+	PreStart  []HookConfig  `yaml:"pre_start,omitempty"`
+	PostStart []HookConfig  `yaml:"post_start,omitempty"`
+	PostStop  []HookConfig  `yaml:"post_stop,omitempty"`
+	Output    *OutputConfig `yaml:"output,omitempty"`
+	// PidFile is written with the PID of the supervised process and removed on its exit.
+	PidFile string `yaml:"pidfile,omitempty"`
 }
 
 type Config struct {
 	Default struct {
-		Runtimes map[string]RuntimeSetting `yaml:"runtimes"`
+		Runtimes  map[string]RuntimeSetting `yaml:"runtimes"`
+		Classpath *ClasspathConfig          `yaml:"classpath,omitempty"`
 	} `yaml:"default"`
 
 	Autodetect struct {
@@ -40,30 +156,39 @@ type Config struct {
 	} `yaml:"autodetect"`
 
 	Services map[string]ServiceConfig `yaml:"services"`
-}
 
-func (h *HealthCheckConfig) ParamToInt(name string) (int, error) {
-	val, found := h.Params[name]
-	if !found {
-		return 0, fmt.Errorf("health check param %q not found", name)
-	}
-	intVal, ok := val.(int)
-	if !ok {
-		return 0, fmt.Errorf("health check param %q is not an integer", name)
-	}
-	return intVal, nil
-}
+	RuntimeDefinitions map[string]RuntimeDefinition `yaml:"runtime_definitions,omitempty"`
 
-func (h *HealthCheckConfig) ParamToString(name string) (string, error) {
-	val, found := h.Params[name]
-	if !found {
-		return "", fmt.Errorf("health check param %q not found", name)
-	}
-	strVal, ok := val.(string)
-	if !ok {
-		return "", fmt.Errorf("health check param %q is not a string", name)
-	}
-	return strVal, nil
+	BigtopUtils struct {
+		DefaultsFile string `yaml:"defaults_file,omitempty"`
+		Disabled     bool   `yaml:"disabled,omitempty"`
+	} `yaml:"bigtop_utils,omitempty"`
+
+	VersionManagers struct {
+		// Disabled skips the installations of SDKMAN, asdf, jenv and pyenv.
+		Disabled bool `yaml:"disabled,omitempty"`
+	} `yaml:"version_managers,omitempty"`
+
+	PackageDatabases struct {
+		// Enabled looks up the runtimes owned by dpkg and rpm packages. It is off by
+		// default since the lookup forks rpm and comes before default.runtimes.
+		Enabled bool `yaml:"enabled,omitempty"`
+	} `yaml:"package_databases,omitempty"`
+
+	API struct {
+		// Listen is a TCP address (host:port) or "unix:/path/to.sock".
+		Listen string `yaml:"listen,omitempty"`
+	} `yaml:"api,omitempty"`
+
+	Cache struct {
+		// Enabled caches resolutions on disk between runs, see detect.Cache.
+		Enabled bool `yaml:"enabled,omitempty"`
+		// Path is the cache file, detect.DefaultCachePath when empty.
+		Path string `yaml:"path,omitempty"`
+	} `yaml:"cache,omitempty"`
+
+	// BigtopDefaults holds the variables read from the bigtop-utils defaults file.
+	BigtopDefaults map[string]string `yaml:"-"`
 }
 
 func Load(path string) (*Config, error) {
@@ -75,7 +200,7 @@ func Load(path string) (*Config, error) {
 	var cfg Config
 	decodeErr := yaml.UnmarshalWithOptions(data, &cfg, yaml.Strict())
 	if decodeErr != nil {
-		return nil, fmt.Errorf("parse config %q: %w", path, decodeErr)
+		return nil, fmt.Errorf("parse config %w", located(path, decodeErr))
 	}
 
 	for name, svc := range cfg.Services {
@@ -102,6 +227,10 @@ func Load(path string) (*Config, error) {
 		cfg.Services[name] = svc
 	}
 
+	if err := loadBigtopDefaults(&cfg); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
@@ -132,7 +261,7 @@ func parseExternalServiceConfig(path string) (ServiceConfig, error) {
 		return cfg, fmt.Errorf("read service config %q: %w", path, err)
 	}
 	if err = yaml.UnmarshalWithOptions(extData, &cfg, yaml.Strict()); err != nil {
-		return cfg, fmt.Errorf("parse service config %q: %w", path, err)
+		return cfg, fmt.Errorf("parse service config %w", located(path, err))
 	}
 	// Abbomination to support env files sourcing
 	// It changes exec to bash and adds source command and the original executable as the args to bash
@@ -146,3 +275,14 @@ func parseExternalServiceConfig(path string) (ServiceConfig, error) {
 	}
 	return cfg, nil
 }
+
+// located prefixes a YAML decoding error with path and, when known, the line
+// and column it refers to: "path:line:column: message".
+func located(path string, err error) error {
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) && yamlErr.GetToken() != nil {
+		pos := yamlErr.GetToken().Position
+		return fmt.Errorf("%s:%d:%d: %s", path, pos.Line, pos.Column, yamlErr.GetMessage())
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
@@ -158,3 +158,67 @@ services:
 		t.Fatal("expected error parsing external service config")
 	}
 }
+
+func TestParseEnvFile(t *testing.T) {
+	t.Setenv("BASE_DIR", "/opt")
+	content := []byte(`# Override JAVA_HOME detection for all bigtop packages
+export JAVA_HOME=/usr/lib/jvm/java-17
+# export JAVA_NATIVE_PATH
+JAVA_NATIVE_PATH="${BASE_DIR}/native"
+export BIGTOP_CLASSPATH='$BIGTOP_CLASSPATH:/opt/extra.jar'
+EXTRA=$JAVA_HOME/lib # trailing comment
+echo ignored
+`)
+	path := filepath.Join(t.TempDir(), "bigtop-utils")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("write defaults: %v", err)
+	}
+
+	vars, err := ParseEnvFile(path)
+	if err != nil {
+		t.Fatalf("ParseEnvFile: %v", err)
+	}
+	want := map[string]string{
+		"JAVA_HOME":        "/usr/lib/jvm/java-17",
+		"JAVA_NATIVE_PATH": "/opt/native",
+		"BIGTOP_CLASSPATH": "$BIGTOP_CLASSPATH:/opt/extra.jar",
+		"EXTRA":            "/usr/lib/jvm/java-17/lib",
+	}
+	if len(vars) != len(want) {
+		t.Errorf("got %v, want %v", vars, want)
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s = %q, want %q", k, vars[k], v)
+		}
+	}
+}
+
+func TestLoad_BigtopDefaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	defaults := filepath.Join(tmpDir, "bigtop-utils")
+	if err := os.WriteFile(defaults, []byte("export JAVA_HOME=/opt/jdk\n"), 0o644); err != nil {
+		t.Fatalf("write defaults: %v", err)
+	}
+	mainFile := filepath.Join(tmpDir, "cfg.yaml")
+	content := "bigtop_utils:\n  defaults_file: " + defaults + "\n"
+	if err := os.WriteFile(mainFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("write main config: %v", err)
+	}
+
+	cfg, err := Load(mainFile)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.BigtopDefaults["JAVA_HOME"] != "/opt/jdk" {
+		t.Errorf("JAVA_HOME = %q, want /opt/jdk", cfg.BigtopDefaults["JAVA_HOME"])
+	}
+
+	content += "  disabled: true\n"
+	if err = os.WriteFile(mainFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("write main config: %v", err)
+	}
+	if cfg, err = Load(mainFile); err != nil || cfg.BigtopDefaults != nil {
+		t.Errorf("disabled defaults = (%v, %v), want (nil, nil)", cfg.BigtopDefaults, err)
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Duration is a time.Duration read from YAML either as a Go duration string
+// ("500ms", "1m30s") or as a number of seconds, possibly quoted ("60").
+type Duration time.Duration
+
+// UnmarshalYAML implements the goccy/go-yaml NodeUnmarshaler.
+func (d *Duration) UnmarshalYAML(node ast.Node) error {
+	raw, err := scalarValue(node)
+	if err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case string:
+		if seconds, parseErr := strconv.ParseFloat(v, 64); parseErr == nil {
+			*d = Duration(seconds * float64(time.Second))
+			return nil
+		}
+		parsed, parseErr := time.ParseDuration(v)
+		if parseErr != nil {
+			return nodeError(node, "invalid duration %q", v)
+		}
+		*d = Duration(parsed)
+	case uint64:
+		*d = Duration(time.Duration(v) * time.Second)
+	case int64:
+		*d = Duration(time.Duration(v) * time.Second)
+	case int:
+		*d = Duration(time.Duration(v) * time.Second)
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	default:
+		return nodeError(node, "invalid duration %v", raw)
+	}
+	return nil
+}
+
+// Std returns d as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestDuration_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{`delay: 1m30s`, 90 * time.Second},
+		{`delay: "500ms"`, 500 * time.Millisecond},
+		{`delay: 5`, 5 * time.Second},
+		{`delay: 0.5`, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		var p RestartPolicy
+		if err := yaml.Unmarshal([]byte(tt.in), &p); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if p.Delay.Std() != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.in, p.Delay.Std(), tt.want)
+		}
+	}
+
+	var p RestartPolicy
+	if err := yaml.Unmarshal([]byte(`delay: soon`), &p); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
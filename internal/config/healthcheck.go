@@ -0,0 +1,314 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Health check types.
+const (
+	HealthCheckPort       = "port"
+	HealthCheckLog        = "log"
+	HealthCheckFile       = "file"
+	HealthCheckTCPConnect = "tcp_connect"
+	HealthCheckTLS        = "tls"
+	HealthCheckUnix       = "unix"
+)
+
+const (
+	// DefaultHealthCheckTimeout is how long a check waits for the service by default.
+	DefaultHealthCheckTimeout = Duration(60 * time.Second)
+	// BindAny requires the port of a port check to be bound to the wildcard address.
+	BindAny              = "any"
+	defaultHost          = "127.0.0.1"
+	defaultProtocol      = "tcp"
+	defaultSocketBackend = "auto"
+	slowPollInterval     = Duration(time.Second)
+	fastPollInterval     = Duration(200 * time.Millisecond)
+	maxPort              = 65535
+)
+
+// HealthCheckConfig is a health check of a service. Params are decoded into the
+// struct of the check type when the config is loaded, see DecodeParams.
+type HealthCheckConfig struct {
+	Type   string         `yaml:"type"`
+	Params map[string]any `yaml:"params,omitempty"`
+
+	decoded checkParams
+}
+
+// PortCheckParams are the params of a port check: the service process listens on Port.
+type PortCheckParams struct {
+	Port           Int      `yaml:"port"`
+	Protocol       string   `yaml:"protocol,omitempty"`
+	Timeout        Duration `yaml:"timeout,omitempty"`
+	Interval       Duration `yaml:"interval,omitempty"`
+	BindAddress    string   `yaml:"bind_address,omitempty"`
+	ForbidLoopback Bool     `yaml:"forbid_loopback,omitempty"`
+	// Backend lists the sockets with "netlink" or from "proc", "auto" picks one.
+	Backend string `yaml:"backend,omitempty"`
+}
+
+// LogCheckParams are the params of a log check: a line written to File, or to the
+// output of the service process without it, matches Pattern.
+type LogCheckParams struct {
+	File           string   `yaml:"file,omitempty"`
+	Stream         string   `yaml:"stream,omitempty"`
+	Pattern        string   `yaml:"pattern"`
+	FailurePattern string   `yaml:"failure_pattern,omitempty"`
+	Timeout        Duration `yaml:"timeout,omitempty"`
+	Interval       Duration `yaml:"interval,omitempty"`
+}
+
+// FileCheckParams are the params of a file check: Path exists.
+type FileCheckParams struct {
+	Path               string   `yaml:"path"`
+	NonEmpty           Bool     `yaml:"non_empty,omitempty"`
+	ModifiedAfterStart Bool     `yaml:"modified_after_start,omitempty"`
+	Timeout            Duration `yaml:"timeout,omitempty"`
+	Interval           Duration `yaml:"interval,omitempty"`
+}
+
+// TCPConnectCheckParams are the params of a tcp_connect check: a connection to Host:Port succeeds.
+type TCPConnectCheckParams struct {
+	Host     string   `yaml:"host,omitempty"`
+	Port     Int      `yaml:"port"`
+	Timeout  Duration `yaml:"timeout,omitempty"`
+	Interval Duration `yaml:"interval,omitempty"`
+}
+
+// TLSCheckParams are the params of a tls check: a TLS handshake with Host:Port completes.
+type TLSCheckParams struct {
+	Host        string   `yaml:"host,omitempty"`
+	Port        Int      `yaml:"port"`
+	Timeout     Duration `yaml:"timeout,omitempty"`
+	Interval    Duration `yaml:"interval,omitempty"`
+	Verify      Bool     `yaml:"verify,omitempty"`
+	ServerName  string   `yaml:"server_name,omitempty"`
+	TrustStore  string   `yaml:"truststore,omitempty"`
+	MinValidity Duration `yaml:"min_validity,omitempty"`
+}
+
+// UnixCheckParams are the params of a unix check: a Unix socket bound to Path,
+// "@name" for an abstract one, listens in the service process tree.
+type UnixCheckParams struct {
+	Path     string   `yaml:"path"`
+	Connect  Bool     `yaml:"connect,omitempty"`
+	Timeout  Duration `yaml:"timeout,omitempty"`
+	Interval Duration `yaml:"interval,omitempty"`
+}
+
+// checkParams are the params of a check type. validate returns a paramError
+// naming the invalid param.
+type checkParams interface {
+	validate() error
+}
+
+type paramError struct {
+	param string
+	msg   string
+}
+
+func (e *paramError) Error() string {
+	return fmt.Sprintf("parameter %s %s", e.param, e.msg)
+}
+
+// newCheckParams returns the params of a check type set to their defaults.
+func newCheckParams(typ string) (checkParams, error) {
+	switch typ {
+	case HealthCheckPort:
+		return &PortCheckParams{
+			Protocol: defaultProtocol,
+			Timeout:  DefaultHealthCheckTimeout,
+			Interval: slowPollInterval,
+			Backend:  defaultSocketBackend,
+		}, nil
+	case HealthCheckLog:
+		return &LogCheckParams{Timeout: DefaultHealthCheckTimeout, Interval: fastPollInterval}, nil
+	case HealthCheckFile:
+		return &FileCheckParams{Timeout: DefaultHealthCheckTimeout, Interval: fastPollInterval}, nil
+	case HealthCheckTCPConnect:
+		return &TCPConnectCheckParams{
+			Host:     defaultHost,
+			Timeout:  DefaultHealthCheckTimeout,
+			Interval: slowPollInterval,
+		}, nil
+	case HealthCheckTLS:
+		return &TLSCheckParams{Host: defaultHost, Timeout: DefaultHealthCheckTimeout, Interval: slowPollInterval}, nil
+	case HealthCheckUnix:
+		return &UnixCheckParams{Timeout: DefaultHealthCheckTimeout, Interval: fastPollInterval}, nil
+	default:
+		return nil, fmt.Errorf("unknown health check type: %s", typ)
+	}
+}
+
+// UnmarshalYAML implements the goccy/go-yaml NodeUnmarshaler. Params are checked
+// against the check type, errors are reported at the line of the offending param.
+func (h *HealthCheckConfig) UnmarshalYAML(node ast.Node) error {
+	var raw struct {
+		Type   string         `yaml:"type"`
+		Params map[string]any `yaml:"params,omitempty"`
+	}
+	if err := yaml.NodeToValue(node, &raw, yaml.Strict()); err != nil {
+		return err
+	}
+	params, err := newCheckParams(raw.Type)
+	if err != nil {
+		return nodeError(orNode(mappingValue(node, "type"), node), "%v", err)
+	}
+	paramsNode := mappingValue(node, "params")
+	if paramsNode != nil && paramsNode.Type() != ast.NullType {
+		if err = yaml.NodeToValue(paramsNode, params, yaml.Strict()); err != nil {
+			return err
+		}
+	}
+	if err = params.validate(); err != nil {
+		var pe *paramError
+		if errors.As(err, &pe) {
+			return nodeError(orNode(mappingValue(paramsNode, pe.param), orNode(paramsNode, node)), "%v", err)
+		}
+		return nodeError(node, "%v", err)
+	}
+	h.Type, h.Params, h.decoded = raw.Type, raw.Params, params
+	return nil
+}
+
+// DecodeParams returns the params decoded into the struct of the check type,
+// e.g. *PortCheckParams. Params set in code rather than loaded are decoded on each call.
+func (h *HealthCheckConfig) DecodeParams() (any, error) {
+	if h.decoded != nil {
+		return h.decoded, nil
+	}
+	data, err := yaml.Marshal(map[string]any{"type": h.Type, "params": h.Params})
+	if err != nil {
+		return nil, err
+	}
+	var decoded HealthCheckConfig
+	if err = yaml.Unmarshal(data, &decoded); err != nil {
+		var yamlErr yaml.Error
+		if errors.As(err, &yamlErr) {
+			return nil, errors.New(yamlErr.GetMessage())
+		}
+		return nil, err
+	}
+	return decoded.decoded, nil
+}
+
+func (p *PortCheckParams) validate() error {
+	if err := validatePort(p.Port); err != nil {
+		return err
+	}
+	switch p.Protocol {
+	case "tcp", "tcp6", "udp", "udp6":
+	default:
+		return &paramError{"protocol", fmt.Sprintf("has invalid value %q", p.Protocol)}
+	}
+	switch p.Backend {
+	case defaultSocketBackend, "netlink", "proc":
+	default:
+		return &paramError{"backend", fmt.Sprintf("has invalid value %q", p.Backend)}
+	}
+	if p.BindAddress != "" && p.BindAddress != BindAny && net.ParseIP(p.BindAddress) == nil {
+		return &paramError{"bind_address", fmt.Sprintf("has invalid value %q", p.BindAddress)}
+	}
+	return validateTiming(p.Timeout, p.Interval)
+}
+
+func (p *LogCheckParams) validate() error {
+	switch p.Stream {
+	case "", "stdout", "stderr":
+	default:
+		return &paramError{"stream", fmt.Sprintf("has invalid value %q", p.Stream)}
+	}
+	if p.Pattern == "" {
+		return &paramError{"pattern", "is missing"}
+	}
+	if _, err := regexp.Compile(p.Pattern); err != nil {
+		return &paramError{"pattern", fmt.Sprintf("has invalid value: %v", err)}
+	}
+	if _, err := regexp.Compile(p.FailurePattern); err != nil {
+		return &paramError{"failure_pattern", fmt.Sprintf("has invalid value: %v", err)}
+	}
+	return validateTiming(p.Timeout, p.Interval)
+}
+
+func (p *FileCheckParams) validate() error {
+	if p.Path == "" {
+		return &paramError{"path", "is missing"}
+	}
+	return validateTiming(p.Timeout, p.Interval)
+}
+
+func (p *UnixCheckParams) validate() error {
+	if p.Path == "" {
+		return &paramError{"path", "is missing"}
+	}
+	return validateTiming(p.Timeout, p.Interval)
+}
+
+func (p *TCPConnectCheckParams) validate() error {
+	if err := validatePort(p.Port); err != nil {
+		return err
+	}
+	return validateTiming(p.Timeout, p.Interval)
+}
+
+func (p *TLSCheckParams) validate() error {
+	if err := validatePort(p.Port); err != nil {
+		return err
+	}
+	if p.MinValidity < 0 {
+		return &paramError{"min_validity", "must not be negative"}
+	}
+	return validateTiming(p.Timeout, p.Interval)
+}
+
+func validatePort(port Int) error {
+	if port == 0 {
+		return &paramError{"port", "is missing"}
+	}
+	if port < 0 || port > maxPort {
+		return &paramError{"port", fmt.Sprintf("has invalid value %d", port)}
+	}
+	return nil
+}
+
+func validateTiming(timeout, interval Duration) error {
+	if timeout < 0 {
+		return &paramError{"timeout", "must not be negative"}
+	}
+	if interval <= 0 {
+		return &paramError{"interval", "must be positive"}
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, nil if there is none.
+func mappingValue(node ast.Node, key string) ast.Node {
+	var values []*ast.MappingValueNode
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		values = n.Values
+	case *ast.MappingValueNode:
+		values = []*ast.MappingValueNode{n}
+	}
+	for _, kv := range values {
+		if kv.Key.String() == key {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+func orNode(node, def ast.Node) ast.Node {
+	if node == nil {
+		return def
+	}
+	return node
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadChecks(t *testing.T, checks string) (*Config, error) {
+	t.Helper()
+	content := "services:\n  kafka:\n    health_checks:\n" + checks
+	tmp := filepath.Join(t.TempDir(), "cfg.yaml")
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return Load(tmp)
+}
+
+func TestLoad_HealthCheckParams(t *testing.T) {
+	cfg, err := loadChecks(t, `
+      - type: port
+        params:
+          port: 9092
+          timeout: 2m
+          forbid_loopback: true
+          backend: proc
+      - type: port
+        params: {port: "9093", timeout: "20", interval: 500ms}
+      - type: log
+        params:
+          pattern: started
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	checks := cfg.Services["kafka"].HealthChecks
+	want := []any{
+		&PortCheckParams{
+			Port: 9092, Protocol: "tcp", Timeout: Duration(2 * time.Minute), Interval: Duration(time.Second),
+			ForbidLoopback: true, Backend: "proc",
+		},
+		&PortCheckParams{
+			Port: 9093, Protocol: "tcp", Timeout: Duration(20 * time.Second),
+			Interval: Duration(500 * time.Millisecond), Backend: "auto",
+		},
+		&LogCheckParams{
+			Pattern:  "started",
+			Timeout:  DefaultHealthCheckTimeout,
+			Interval: Duration(200 * time.Millisecond),
+		},
+	}
+	for i, check := range checks {
+		params, decodeErr := check.DecodeParams()
+		if decodeErr != nil {
+			t.Errorf("check %d: %v", i, decodeErr)
+			continue
+		}
+		switch p := params.(type) {
+		case *PortCheckParams:
+			if *p != *want[i].(*PortCheckParams) {
+				t.Errorf("check %d = %+v, want %+v", i, p, want[i])
+			}
+		case *LogCheckParams:
+			if *p != *want[i].(*LogCheckParams) {
+				t.Errorf("check %d = %+v, want %+v", i, p, want[i])
+			}
+		default:
+			t.Errorf("check %d: unexpected params %T", i, params)
+		}
+	}
+}
+
+func TestLoad_HealthCheckErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		checks string
+		want   string
+	}{
+		{
+			name:   "not a number",
+			checks: "      - type: port\n        params:\n          port: kafka\n",
+			want:   `cfg.yaml:6:17: invalid integer "kafka"`,
+		},
+		{
+			name:   "unknown param",
+			checks: "      - type: port\n        params:\n          port: 9092\n          retries: 3\n",
+			want:   `cfg.yaml:7:11: unknown field "retries"`,
+		},
+		{
+			name:   "missing param",
+			checks: "      - type: tcp_connect\n        params:\n          host: kafka-1\n",
+			want:   "cfg.yaml:6:15: parameter port is missing",
+		},
+		{
+			name:   "invalid duration",
+			checks: "      - type: file\n        params:\n          path: /tmp/ready\n          timeout: soon\n",
+			want:   `cfg.yaml:7:20: invalid duration "soon"`,
+		},
+		{
+			name:   "invalid pattern",
+			checks: "      - type: log\n        params:\n          pattern: '('\n",
+			want:   "cfg.yaml:6:20: parameter pattern has invalid value",
+		},
+		{
+			name:   "unknown type",
+			checks: "      - type: http\n",
+			want:   "cfg.yaml:4:15: unknown health check type: http",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadChecks(t, tc.checks)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load = %v, want error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestHealthCheckConfig_DecodeParams(t *testing.T) {
+	check := HealthCheckConfig{Type: HealthCheckTLS, Params: map[string]any{"port": 9093, "verify": "true"}}
+	params, err := check.DecodeParams()
+	if err != nil {
+		t.Fatalf("DecodeParams: %v", err)
+	}
+	if p := params.(*TLSCheckParams); p.Port != 9093 || !p.Verify || p.Host != "127.0.0.1" {
+		t.Errorf("params = %+v", p)
+	}
+
+	check.Params["port"] = 70000
+	if _, err = check.DecodeParams(); err == nil || strings.Contains(err.Error(), "\n") {
+		t.Errorf("DecodeParams = %v, want a one-line error", err)
+	}
+}
+
+func TestLoad_Example(t *testing.T) {
+	svc, err := parseExternalServiceConfig(filepath.Join("..", "..", "examples", "config", "kafka.yaml"))
+	if err != nil {
+		t.Fatalf("parse example: %v", err)
+	}
+	if len(svc.HealthChecks) == 0 {
+		t.Fatal("no health checks in the example")
+	}
+	params, err := svc.HealthChecks[0].DecodeParams()
+	if err != nil || params.(*PortCheckParams).Port != 9092 {
+		t.Errorf("first check = %+v, %v", params, err)
+	}
+}
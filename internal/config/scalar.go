@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Int is an int read from YAML either as a number or as a numeric string.
+type Int int
+
+// UnmarshalYAML implements the goccy/go-yaml NodeUnmarshaler.
+func (i *Int) UnmarshalYAML(node ast.Node) error {
+	raw, err := scalarValue(node)
+	if err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case string:
+		n, parseErr := strconv.Atoi(v)
+		if parseErr != nil {
+			return nodeError(node, "invalid integer %q", v)
+		}
+		*i = Int(n)
+	case uint64:
+		*i = Int(v)
+	case int64:
+		*i = Int(v)
+	case int:
+		*i = Int(v)
+	default:
+		return nodeError(node, "invalid integer %v", raw)
+	}
+	return nil
+}
+
+// Bool is a bool read from YAML either as a boolean or as a string such as "true".
+type Bool bool
+
+// UnmarshalYAML implements the goccy/go-yaml NodeUnmarshaler.
+func (b *Bool) UnmarshalYAML(node ast.Node) error {
+	raw, err := scalarValue(node)
+	if err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case bool:
+		*b = Bool(v)
+	case string:
+		parsed, parseErr := strconv.ParseBool(v)
+		if parseErr != nil {
+			return nodeError(node, "invalid boolean %q", v)
+		}
+		*b = Bool(parsed)
+	default:
+		return nodeError(node, "invalid boolean %v", raw)
+	}
+	return nil
+}
+
+func scalarValue(node ast.Node) (any, error) {
+	var raw any
+	if err := yaml.NodeToValue(node, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// nodeError returns an error reported at the line and column of node.
+func nodeError(node ast.Node, format string, args ...any) error {
+	return &yaml.SyntaxError{Message: fmt.Sprintf(format, args...), Token: node.GetToken()}
+}
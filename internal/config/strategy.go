@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Detection strategies of a runtime, see RuntimeSetting.Strategies.
+const (
+	StrategyOverridePath = "override_path"
+	StrategyEnvVar       = "env_var"
+	StrategyPaths        = "paths"
+	StrategyAlternatives = "alternatives"
+	StrategyWhich        = "which"
+)
+
+// DefaultStrategies are the strategies of a runtime without strategies.
+func DefaultStrategies() []Strategy {
+	return []Strategy{StrategyOverridePath, StrategyEnvVar, StrategyPaths}
+}
+
+// Strategy is the name of a detection strategy, validated when read from YAML.
+type Strategy string
+
+// UnmarshalYAML implements the goccy/go-yaml NodeUnmarshaler.
+func (s *Strategy) UnmarshalYAML(node ast.Node) error {
+	raw, err := scalarValue(node)
+	if err != nil {
+		return err
+	}
+	name, ok := raw.(string)
+	if !ok {
+		return nodeError(node, "invalid strategy %v", raw)
+	}
+	switch name {
+	case StrategyOverridePath, StrategyEnvVar, StrategyPaths, StrategyAlternatives, StrategyWhich:
+		*s = Strategy(name)
+		return nil
+	default:
+		return nodeError(node, "unknown strategy %q", name)
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestStrategy_UnmarshalYAML(t *testing.T) {
+	var rt RuntimeSetting
+	if err := yaml.Unmarshal([]byte(`strategies: [alternatives, which, paths]`), &rt); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := []Strategy{StrategyAlternatives, StrategyWhich, StrategyPaths}
+	if len(rt.Strategies) != len(want) || rt.Strategies[0] != want[0] || rt.Strategies[2] != want[2] {
+		t.Errorf("strategies = %v, want %v", rt.Strategies, want)
+	}
+	err := yaml.Unmarshal([]byte(`strategies: [rpm]`), &rt)
+	if err == nil || !strings.Contains(err.Error(), `unknown strategy "rpm"`) {
+		t.Errorf("err = %v", err)
+	}
+}
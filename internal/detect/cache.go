@@ -0,0 +1,289 @@
+package detect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// DefaultCachePath is where resolutions are cached between runs.
+const DefaultCachePath = "/run/ad-runtime-utils/cache.json"
+
+// cacheFile is the on-disk form of a Cache.
+type cacheFile struct {
+	ConfigHash string                `json:"config_hash"`
+	Entries    map[string]cacheEntry `json:"entries"`
+}
+
+// cacheEntry is a cached resolution, valid as long as the paths it depends on
+// keep their modification times and the environment variables it read their values.
+type cacheEntry struct {
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+	// Mtimes are in nanoseconds since the epoch, 0 for a path that did not exist.
+	Mtimes map[string]int64   `json:"mtimes"`
+	Env    map[string]*string `json:"env,omitempty"`
+}
+
+// Cache is an on-disk cache of runtime and cacerts resolutions for one config,
+// so that scripts calling ad-runtime-utils many times do not glob the runtime
+// paths and walk the JDK tree every time. Entries are dropped when the config
+// changes, when a matched path or candidate directory is modified and when an
+// environment variable used by the resolution changes. Failed resolutions are
+// not cached. A nil *Cache resolves without caching.
+type Cache struct {
+	path  string
+	file  cacheFile
+	dirty bool
+}
+
+// OpenCache returns the cache at path for cfg. A missing, unreadable or
+// outdated cache file gives an empty cache, a config that cannot be hashed a nil one.
+func OpenCache(path string, cfg *config.Config) *Cache {
+	c := &Cache{path: path, file: cacheFile{Entries: make(map[string]cacheEntry)}}
+	hash, err := configHash(cfg)
+	if err != nil {
+		return nil
+	}
+	var file cacheFile
+	if data, readErr := os.ReadFile(path); readErr == nil && json.Unmarshal(data, &file) == nil &&
+		file.ConfigHash == hash && file.Entries != nil {
+		c.file = file
+	}
+	c.file.ConfigHash = hash
+	return c
+}
+
+// configHash returns the hash of the loaded config, which includes the external
+// service configs and the bigtop-utils defaults.
+func configHash(cfg *config.Config) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Resolve is like the package-level Resolve, answering from the cache when possible.
+func (c *Cache) Resolve(cfg *config.Config, service, runtime string) (Resolution, error) {
+	if c == nil {
+		return Resolve(cfg, service, runtime)
+	}
+	key := "runtime:" + service + ":" + runtime
+	if e, ok := c.lookup(key); ok {
+		return Resolution{Path: e.Path, Source: e.Source}, nil
+	}
+	res, err := Resolve(cfg, service, runtime)
+	if err != nil {
+		return res, err
+	}
+	paths, env := runtimeDeps(cfg, service, runtime, res.Path)
+	c.store(key, res.Path, res.Source, paths, env)
+	return res, nil
+}
+
+// ResolveRuntime is like the package-level ResolveRuntime, answering from the cache when possible.
+func (c *Cache) ResolveRuntime(cfg *config.Config, service, runtime string) (string, error) {
+	res, err := c.Resolve(cfg, service, runtime)
+	return res.Path, err
+}
+
+// FindCACerts is like the package-level FindCACerts, answering from the cache when possible.
+func (c *Cache) FindCACerts(javaHome string, opts *CACertsOptions) (string, error) {
+	if c == nil {
+		return FindCACerts(javaHome, opts)
+	}
+	o := mergeCACertsOptions(opts)
+	key := "cacerts:" + javaHome + ":" + strings.Join(o.KnownSystemPaths, ",")
+	if e, ok := c.lookup(key); ok {
+		return e.Path, nil
+	}
+	p, err := FindCACerts(javaHome, opts)
+	if err != nil {
+		return p, err
+	}
+	paths := []string{p, filepath.Dir(p)}
+	if javaHome != "" {
+		jh := evalSymlinkOr(javaHome)
+		paths = append(paths, javaHome, jh,
+			filepath.Join(jh, "lib", "security"), filepath.Join(jh, "jre", "lib", "security"))
+	}
+	c.store(key, p, "", append(paths, o.KnownSystemPaths...), nil)
+	return p, nil
+}
+
+// Save writes the cache file when entries were added, creating its directory.
+// The file is replaced atomically, so concurrent runs never read a partial one.
+func (c *Cache) Save() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.file)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(c.path)
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func (c *Cache) lookup(key string) (cacheEntry, bool) {
+	e, ok := c.file.Entries[key]
+	if !ok {
+		return e, false
+	}
+	for p, mtime := range e.Mtimes {
+		if modTime(p) != mtime {
+			delete(c.file.Entries, key)
+			c.dirty = true
+			return e, false
+		}
+	}
+	for name, value := range e.Env {
+		if current, set := os.LookupEnv(name); set != (value != nil) || set && current != *value {
+			delete(c.file.Entries, key)
+			c.dirty = true
+			return e, false
+		}
+	}
+	return e, true
+}
+
+func (c *Cache) store(key, path, source string, paths, env []string) {
+	e := cacheEntry{Path: path, Source: source, Mtimes: make(map[string]int64, len(paths))}
+	for _, p := range paths {
+		e.Mtimes[p] = modTime(p)
+	}
+	if len(env) > 0 {
+		e.Env = make(map[string]*string, len(env))
+		for _, name := range env {
+			if value, ok := os.LookupEnv(name); ok {
+				e.Env[name] = &value
+			} else {
+				e.Env[name] = nil
+			}
+		}
+	}
+	c.file.Entries[key] = e
+	c.dirty = true
+}
+
+// modTime returns the modification time of p, of the link itself for a symlink,
+// so that pointing it elsewhere is noticed.
+func modTime(p string) int64 {
+	st, err := os.Lstat(p)
+	if err != nil {
+		return 0
+	}
+	return st.ModTime().UnixNano()
+}
+
+// runtimeDeps returns the paths and environment variables the resolution of the
+// runtime depends on: the installation directory found and its executable
+// directory, the override paths, env_var values, the directories the paths
+// patterns are matched in, the alternatives links, the executables found on
+// PATH, the installations of the version managers and the package databases,
+// of every step of the resolution chain.
+func runtimeDeps(cfg *config.Config, service, runtime, home string) ([]string, []string) {
+	exes := executables(Definition(cfg, runtime))
+	paths := []string{home}
+	if exe, ok := findExecutable(home, exes); ok {
+		paths = append(paths, filepath.Dir(exe))
+	}
+	var env []string
+	expand := func(p string) string {
+		if strings.HasPrefix(p, "~") {
+			env = append(env, "HOME")
+		}
+		os.Expand(p, func(name string) string {
+			env = append(env, name)
+			return ""
+		})
+		return expandPath(p)
+	}
+
+	settings := []config.RuntimeSetting{cfg.Services[service].Runtimes[runtime], cfg.Default.Runtimes[runtime]}
+	for _, s := range cfg.Autodetect.Runtimes[runtime] {
+		settings = append(settings, s)
+	}
+	for _, s := range settings {
+		if s.OverridePath != "" {
+			paths = append(paths, expand(s.OverridePath))
+		}
+		if s.EnvVar != "" {
+			env = append(env, s.EnvVar)
+			if raw := os.Getenv(s.EnvVar); raw != "" {
+				paths = append(paths, expand(raw))
+			}
+		}
+		for _, pat := range s.Paths {
+			base := expand(pat)
+			if i := strings.IndexAny(base, "*?["); i >= 0 {
+				paths = append(paths, filepath.Dir(base[:i]))
+				continue
+			}
+			// a path that does not exist is matched as a prefix, see tryPaths
+			paths = append(paths, base, filepath.Dir(base))
+		}
+		if slices.Contains(s.Strategies, config.StrategyAlternatives) {
+			names := s.Alternatives
+			if len(names) == 0 {
+				for _, exe := range exes {
+					names = append(names, filepath.Base(exe))
+				}
+			}
+			// update-alternatives replaces the link, which changes its own mtime
+			for _, name := range names {
+				paths = append(paths, filepath.Join(alternativesDir, name))
+			}
+		}
+		if slices.Contains(s.Strategies, config.StrategyWhich) {
+			env = append(env, "PATH")
+			for _, exe := range exes {
+				if found, err := exec.LookPath(filepath.Base(exe)); err == nil {
+					paths = append(paths, found, filepath.Dir(found))
+				}
+			}
+		}
+	}
+	if !cfg.VersionManagers.Disabled {
+		env = append(env, "HOME", "SDKMAN_DIR", "ASDF_DATA_DIR", "JENV_ROOT", "PYENV_ROOT")
+		if userHome, err := os.UserHomeDir(); err == nil {
+			_, managerPaths := managedInstalls(userHome, runtime)
+			paths = append(paths, managerPaths...)
+		}
+	}
+	if cfg.PackageDatabases.Enabled {
+		paths = append(paths, packageDatabaseFiles()...)
+	}
+	return paths, env
+}
@@ -0,0 +1,119 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func cacheConfig(pattern string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Default.Runtimes = map[string]config.RuntimeSetting{
+		"java": {Version: "17", EnvVar: "AD_RUNTIME_UTILS_TEST_JAVA_HOME", Paths: []string{pattern}},
+	}
+	return cfg
+}
+
+// reopen saves c and opens the cache file again, as the next run would.
+func reopen(t *testing.T, c *Cache, path string, cfg *config.Config) *Cache {
+	t.Helper()
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return OpenCache(path, cfg)
+}
+
+func TestCache_Resolve(t *testing.T) {
+	base := t.TempDir()
+	jdk17 := makeDir(t, base, "jdk-17", "java")
+	cachePath := filepath.Join(t.TempDir(), "run", "cache.json")
+	cfg := cacheConfig(filepath.Join(base, "jdk-*"))
+
+	c := OpenCache(cachePath, cfg)
+	if got, err := c.ResolveRuntime(cfg, "", "java"); err != nil || got != jdk17 {
+		t.Fatalf("ResolveRuntime = %q, %v", got, err)
+	}
+	c = reopen(t, c, cachePath, cfg)
+	if _, ok := c.lookup("runtime::java"); !ok {
+		t.Fatal("resolution not cached")
+	}
+
+	// a newer installation in the globbed directory, modification times are coarse
+	time.Sleep(20 * time.Millisecond)
+	jdk21 := makeDir(t, base, "jdk-21", "java")
+	if got, _ := c.ResolveRuntime(cfg, "", "java"); got != jdk21 {
+		t.Errorf("after a new installation = %q, want %q", got, jdk21)
+	}
+
+	// the executable removed from the cached installation
+	c = reopen(t, c, cachePath, cfg)
+	time.Sleep(20 * time.Millisecond)
+	if err := os.Remove(filepath.Join(jdk21, "bin", "java")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.ResolveRuntime(cfg, "", "java"); got != jdk17 {
+		t.Errorf("after removing java = %q, want %q", got, jdk17)
+	}
+
+	// env_var takes precedence once set
+	c = reopen(t, c, cachePath, cfg)
+	custom := makeDir(t, t.TempDir(), "custom", "java")
+	t.Setenv("AD_RUNTIME_UTILS_TEST_JAVA_HOME", custom)
+	if got, _ := c.ResolveRuntime(cfg, "", "java"); got != custom {
+		t.Errorf("after setting env_var = %q, want %q", got, custom)
+	}
+
+	// another config drops the entries
+	c = reopen(t, c, cachePath, cfg)
+	other := cacheConfig(filepath.Join(base, "jre-*"))
+	if c = OpenCache(cachePath, other); len(c.file.Entries) != 0 {
+		t.Errorf("entries of another config = %v", c.file.Entries)
+	}
+}
+
+func TestCache_FindCACerts(t *testing.T) {
+	jh := t.TempDir()
+	security := filepath.Join(jh, "lib", "security")
+	if err := os.MkdirAll(security, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cacerts := filepath.Join(security, "cacerts")
+	if err := os.WriteFile(cacerts, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	cfg := cacheConfig("/nonexistent")
+	opts := &CACertsOptions{KnownSystemPaths: []string{}}
+
+	c := OpenCache(cachePath, cfg)
+	if got, err := c.FindCACerts(jh, opts); err != nil || got != cacerts {
+		t.Fatalf("FindCACerts = %q, %v", got, err)
+	}
+	c = reopen(t, c, cachePath, cfg)
+	if got, err := c.FindCACerts(jh, opts); err != nil || got != cacerts {
+		t.Errorf("cached FindCACerts = %q, %v", got, err)
+	}
+
+	if err := os.Remove(cacerts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.FindCACerts(jh, opts); err == nil {
+		t.Error("expected error once cacerts is removed")
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	base := t.TempDir()
+	jdk := makeDir(t, base, "jdk", "java")
+	cfg := cacheConfig(jdk)
+	var c *Cache
+	if got, err := c.ResolveRuntime(cfg, "", "java"); err != nil || got != jdk {
+		t.Errorf("ResolveRuntime = %q, %v", got, err)
+	}
+	if err := c.Save(); err != nil {
+		t.Errorf("Save: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package detect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const (
+	defaultClasspathDir    = "/var/lib/bigtop"
+	defaultClasspathEnvVar = "BIGTOP_CLASSPATH"
+	defaultClasspathGlob   = "*.jar"
+	classpathEnv           = "CLASSPATH"
+)
+
+// jarQualifierRe matches the hyphen-separated qualifiers that belong to a
+// version, as in 1.0-SNAPSHOT or 2.0-rc1.
+var jarQualifierRe = regexp.MustCompile(
+	`(?i)^(snapshot|alpha\d*|beta\d*|rc\d*|cr\d*|m\d+|ga|final|release|incubating)$`)
+
+// Classpath is the result of AssembleClasspath.
+// EnvVar is the variable the classpath is exported as.
+// Entries are the classpath entries in order.
+// Duplicates lists artifacts found more than once (e.g. two versions of the same jar),
+// jars with a classifier (e.g. -tests) count as a separate artifact.
+type Classpath struct {
+	EnvVar     string
+	Entries    []string
+	Duplicates []DuplicateArtifact
+}
+
+// DuplicateArtifact is an artifact name, with its classifier after a colon if
+// any, and all the jars providing it.
+type DuplicateArtifact struct {
+	Artifact string
+	Paths    []string
+}
+
+func (d DuplicateArtifact) String() string {
+	return fmt.Sprintf("%s: %s", d.Artifact, strings.Join(d.Paths, ", "))
+}
+
+// String returns the entries joined with the path list separator.
+func (c Classpath) String() string {
+	return strings.Join(c.Entries, string(os.PathListSeparator))
+}
+
+// ClasspathSettings returns the effective classpath settings for the service:
+// built-in defaults overlaid with default.classpath and services.<name>.classpath.
+func ClasspathSettings(cfg *config.Config, service string) config.ClasspathConfig {
+	eff := config.ClasspathConfig{
+		Dirs:    []string{defaultClasspathDir},
+		Include: []string{defaultClasspathGlob},
+		EnvVar:  defaultClasspathEnvVar,
+	}
+	overlayClasspath(&eff, cfg.Default.Classpath)
+	if svc, ok := cfg.Services[service]; ok && service != "" {
+		overlayClasspath(&eff, svc.Classpath)
+	}
+	return eff
+}
+
+func overlayClasspath(dst *config.ClasspathConfig, src *config.ClasspathConfig) {
+	if src == nil {
+		return
+	}
+	if len(src.Dirs) > 0 {
+		dst.Dirs = src.Dirs
+	}
+	if len(src.Include) > 0 {
+		dst.Include = src.Include
+	}
+	if len(src.Exclude) > 0 {
+		dst.Exclude = src.Exclude
+	}
+	if src.EnvVar != "" {
+		dst.EnvVar = src.EnvVar
+	}
+	dst.UseEnvClasspath = dst.UseEnvClasspath || src.UseEnvClasspath
+	dst.FailOnDuplicates = dst.FailOnDuplicates || src.FailOnDuplicates
+}
+
+// AssembleClasspath builds the classpath for the service.
+// Entries already exported in the target variable (e.g. $BIGTOP_CLASSPATH) come first,
+// then the ones set for it in the bigtop-utils defaults file, then entries of $CLASSPATH
+// (if use_env_classpath is set), followed by the files of each directory in config order
+// matching include and not exclude, sorted by name.
+// Duplicated entries are dropped.
+// An error is returned only if fail_on_duplicates is set and duplicates are found.
+func AssembleClasspath(cfg *config.Config, service string) (Classpath, error) {
+	settings := ClasspathSettings(cfg, service)
+	cp := Classpath{EnvVar: settings.EnvVar}
+
+	entries := filepath.SplitList(os.Getenv(settings.EnvVar))
+	entries = append(entries, filepath.SplitList(cfg.BigtopDefaults[settings.EnvVar])...)
+	if settings.UseEnvClasspath {
+		entries = append(entries, filepath.SplitList(os.Getenv(classpathEnv))...)
+	}
+	for _, dir := range expandDirs(settings.Dirs) {
+		entries = append(entries, listClasspathDir(dir, settings.Include, settings.Exclude)...)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if e == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		cp.Entries = append(cp.Entries, e)
+	}
+	cp.Duplicates = findDuplicateArtifacts(cp.Entries)
+
+	if settings.FailOnDuplicates && len(cp.Duplicates) > 0 {
+		return cp, fmt.Errorf("duplicate artifacts in classpath: %s", cp.Duplicates[0])
+	}
+	return cp, nil
+}
+
+// expandDirs expands '~', environment variables and globs in dirs, keeping config order.
+func expandDirs(dirs []string) []string {
+	var out []string
+	for _, d := range dirs {
+		d = expandPath(d)
+		if !hasGlobMeta(d) {
+			out = append(out, d)
+			continue
+		}
+		matches, _ := filepath.Glob(d)
+		sort.Strings(matches)
+		out = append(out, matches...)
+	}
+	return out
+}
+
+func listClasspathDir(dir string, include, exclude []string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !matchAny(include, name) || matchAny(exclude, name) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	// os.ReadDir already sorts by name
+	return files
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitJarName splits the file name of a jar, <artifact>-<version>[-<classifier>].jar,
+// into its parts. The version is the last run of parts starting with a digit, so
+// hadoop-common-3.3.6-tests.jar has the classifier "tests" and log4j-1.2-api-2.17.1.jar
+// is the artifact log4j-1.2-api. A jar without a version is all artifact.
+func splitJarName(jar string) (string, string, string) {
+	name := strings.TrimSuffix(filepath.Base(jar), ".jar")
+	parts := strings.Split(name, "-")
+	isVersion := func(part string) bool {
+		return part != "" && part[0] >= '0' && part[0] <= '9'
+	}
+	end := len(parts)
+	for end > 1 && !isVersion(parts[end-1]) && !jarQualifierRe.MatchString(parts[end-1]) {
+		end--
+	}
+	start := end
+	for start > 1 && (isVersion(parts[start-1]) || jarQualifierRe.MatchString(parts[start-1])) {
+		start--
+	}
+	if start == end || !isVersion(parts[start]) {
+		return name, "", ""
+	}
+	return strings.Join(parts[:start], "-"), strings.Join(parts[start:end], "-"), strings.Join(parts[end:], "-")
+}
+
+// artifactKey identifies the artifact a jar provides: its name and classifier,
+// so that the tests or sources jar of an artifact is not a duplicate of it.
+func artifactKey(jar string) string {
+	artifact, _, classifier := splitJarName(jar)
+	if classifier == "" {
+		return artifact
+	}
+	return artifact + ":" + classifier
+}
+
+func findDuplicateArtifacts(entries []string) []DuplicateArtifact {
+	byArtifact := make(map[string][]string)
+	var order []string
+	for _, e := range entries {
+		if !strings.HasSuffix(e, ".jar") {
+			continue
+		}
+		name := artifactKey(e)
+		if _, ok := byArtifact[name]; !ok {
+			order = append(order, name)
+		}
+		byArtifact[name] = append(byArtifact[name], e)
+	}
+	var dups []DuplicateArtifact
+	for _, name := range order {
+		if paths := byArtifact[name]; len(paths) > 1 {
+			dups = append(dups, DuplicateArtifact{Artifact: name, Paths: paths})
+		}
+	}
+	return dups
+}
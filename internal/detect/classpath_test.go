@@ -0,0 +1,134 @@
+package detect
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestAssembleClasspath_OrderIncludeExclude(t *testing.T) {
+	t.Setenv("SVC_CLASSPATH", "")
+	tmp := t.TempDir()
+	libA := filepath.Join(tmp, "a")
+	libB := filepath.Join(tmp, "b")
+	mustWriteFile(t, filepath.Join(libA, "zookeeper-3.8.4.jar"), nil)
+	mustWriteFile(t, filepath.Join(libA, "commons-io-2.11.0.jar"), nil)
+	mustWriteFile(t, filepath.Join(libA, "commons-io-2.11.0-sources.jar"), nil)
+	mustWriteFile(t, filepath.Join(libA, "README"), nil)
+	mustWriteFile(t, filepath.Join(libB, "guava-32.1.2-jre.jar"), nil)
+
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"svc": {Classpath: &config.ClasspathConfig{
+			Dirs:    []string{libB, libA, libB},
+			Exclude: []string{"*-sources.jar"},
+			EnvVar:  "SVC_CLASSPATH",
+		}},
+	}}
+
+	cp, err := AssembleClasspath(cfg, "svc")
+	if err != nil {
+		t.Fatalf("AssembleClasspath: %v", err)
+	}
+	want := []string{
+		filepath.Join(libB, "guava-32.1.2-jre.jar"),
+		filepath.Join(libA, "commons-io-2.11.0.jar"),
+		filepath.Join(libA, "zookeeper-3.8.4.jar"),
+	}
+	if !reflect.DeepEqual(cp.Entries, want) {
+		t.Errorf("entries = %v, want %v", cp.Entries, want)
+	}
+	if cp.EnvVar != "SVC_CLASSPATH" {
+		t.Errorf("env var = %q, want SVC_CLASSPATH", cp.EnvVar)
+	}
+	if len(cp.Duplicates) != 0 {
+		t.Errorf("unexpected duplicates: %v", cp.Duplicates)
+	}
+}
+
+func TestAssembleClasspath_Duplicates(t *testing.T) {
+	t.Setenv(defaultClasspathEnvVar, "")
+	tmp := t.TempDir()
+	mustWriteFile(t, filepath.Join(tmp, "a", "kafka-clients-3.4.0.jar"), nil)
+	mustWriteFile(t, filepath.Join(tmp, "b", "kafka-clients-3.7.1.jar"), nil)
+
+	settings := &config.ClasspathConfig{Dirs: []string{filepath.Join(tmp, "*")}}
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{"svc": {Classpath: settings}}}
+
+	cp, err := AssembleClasspath(cfg, "svc")
+	if err != nil {
+		t.Fatalf("AssembleClasspath: %v", err)
+	}
+	if len(cp.Duplicates) != 1 || cp.Duplicates[0].Artifact != "kafka-clients" {
+		t.Fatalf("duplicates = %v, want kafka-clients", cp.Duplicates)
+	}
+
+	settings.FailOnDuplicates = true
+	if _, err = AssembleClasspath(cfg, "svc"); err == nil {
+		t.Fatal("expected error with fail_on_duplicates")
+	}
+}
+
+func TestAssembleClasspath_EnvClasspath(t *testing.T) {
+	tmp := t.TempDir()
+	jar := filepath.Join(tmp, "hadoop-common-3.3.6.jar")
+	mustWriteFile(t, jar, nil)
+	t.Setenv("CLASSPATH", "/etc/hadoop/conf:"+jar)
+	t.Setenv(defaultClasspathEnvVar, "/opt/extra.jar")
+
+	cfg := &config.Config{}
+	cfg.Default.Classpath = &config.ClasspathConfig{Dirs: []string{tmp}, UseEnvClasspath: true}
+
+	cp, err := AssembleClasspath(cfg, "")
+	if err != nil {
+		t.Fatalf("AssembleClasspath: %v", err)
+	}
+	want := []string{"/opt/extra.jar", "/etc/hadoop/conf", jar}
+	if !reflect.DeepEqual(cp.Entries, want) {
+		t.Errorf("entries = %v, want %v", cp.Entries, want)
+	}
+	if cp.EnvVar != defaultClasspathEnvVar {
+		t.Errorf("env var = %q, want %q", cp.EnvVar, defaultClasspathEnvVar)
+	}
+}
+
+func TestArtifactKey(t *testing.T) {
+	cases := map[string]string{
+		"hadoop-common-3.3.6.jar":                                     "hadoop-common",
+		"hadoop-common-3.3.6-tests.jar":                               "hadoop-common:tests",
+		"kafka-clients-3.4.0-sources.jar":                             "kafka-clients:sources",
+		"kafka-clients-3.4.0-javadoc.jar":                             "kafka-clients:javadoc",
+		"hbase-shaded-client-2.5.5-shaded.jar":                        "hbase-shaded-client:shaded",
+		"log4j-1.2-api-2.17.1.jar":                                    "log4j-1.2-api",
+		"log4j-1.2.17.jar":                                            "log4j",
+		"foo-1.0-SNAPSHOT.jar":                                        "foo",
+		"foo-1.0-SNAPSHOT-tests.jar":                                  "foo:tests",
+		"netty-transport-native-epoll-4.1.100.Final-linux-x86_64.jar": "netty-transport-native-epoll:linux-x86_64",
+		"hadoop-aws.jar":                                              "hadoop-aws",
+	}
+	for jar, want := range cases {
+		if got := artifactKey(jar); got != want {
+			t.Errorf("artifactKey(%q) = %q, want %q", jar, got, want)
+		}
+	}
+}
+
+func TestFindDuplicateArtifacts_Classifiers(t *testing.T) {
+	entries := []string{
+		"/lib/hadoop-common-3.3.6.jar",
+		"/lib/hadoop-common-3.3.6-tests.jar",
+		"/lib/log4j-1.2-api-2.17.1.jar",
+		"/lib/log4j-1.2.17.jar",
+		"/lib/kafka-clients-3.4.0-sources.jar",
+		"/lib/kafka-clients-3.4.0.jar",
+	}
+	if dups := findDuplicateArtifacts(entries); len(dups) != 0 {
+		t.Errorf("unexpected duplicates: %v", dups)
+	}
+	entries = append(entries, "/lib/hadoop-common-3.4.0-tests.jar")
+	dups := findDuplicateArtifacts(entries)
+	if len(dups) != 1 || dups[0].Artifact != "hadoop-common:tests" {
+		t.Errorf("duplicates = %v, want hadoop-common:tests", dups)
+	}
+}
@@ -0,0 +1,172 @@
+package detect
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	goruntime "runtime"
+	"strings"
+)
+
+// Skipped is a candidate installation rejected because its executable cannot
+// run on this host.
+type Skipped struct {
+	Path   string
+	Reason string
+}
+
+func (s Skipped) String() string {
+	return "skipped " + s.Path + ": " + s.Reason
+}
+
+// skipLog collects the candidates skipped during one resolution. A nil log
+// still rejects incompatible candidates, it only does not record them.
+type skipLog struct {
+	skipped []Skipped
+}
+
+// usable reports whether one of exes exists in home and can run on this host,
+// recording the reason when it cannot.
+func (l *skipLog) usable(home string, exes []string) bool {
+	exe, ok := findExecutable(home, exes)
+	if !ok {
+		return false
+	}
+	if err := checkCompatible(exe); err != nil {
+		l.add(home, err.Error())
+		return false
+	}
+	return true
+}
+
+func (l *skipLog) add(path, reason string) {
+	if l == nil {
+		return
+	}
+	for _, s := range l.skipped {
+		if s.Path == path {
+			return
+		}
+	}
+	l.skipped = append(l.skipped, Skipped{Path: path, Reason: reason})
+}
+
+func (l *skipLog) list() []Skipped {
+	if l == nil {
+		return nil
+	}
+	return l.skipped
+}
+
+// notFound returns the error of a failed resolution, listing the skipped candidates.
+func (l *skipLog) notFound(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	for _, s := range l.list() {
+		msg += "; " + s.String()
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// elfTarget is the machine and byte order of ELF executables of a GOARCH.
+type elfTarget struct {
+	machine elf.Machine
+	data    elf.Data
+}
+
+// elfTargetOf returns the ELF target of the Go architecture arch.
+func elfTargetOf(arch string) (elfTarget, bool) {
+	switch arch {
+	case "amd64":
+		return elfTarget{elf.EM_X86_64, elf.ELFDATA2LSB}, true
+	case "386":
+		return elfTarget{elf.EM_386, elf.ELFDATA2LSB}, true
+	case "arm64":
+		return elfTarget{elf.EM_AARCH64, elf.ELFDATA2LSB}, true
+	case "arm":
+		return elfTarget{elf.EM_ARM, elf.ELFDATA2LSB}, true
+	case "ppc64le":
+		return elfTarget{elf.EM_PPC64, elf.ELFDATA2LSB}, true
+	case "ppc64":
+		return elfTarget{elf.EM_PPC64, elf.ELFDATA2MSB}, true
+	case "s390x":
+		return elfTarget{elf.EM_S390, elf.ELFDATA2MSB}, true
+	case "riscv64":
+		return elfTarget{elf.EM_RISCV, elf.ELFDATA2LSB}, true
+	case "loong64":
+		return elfTarget{elf.EM_LOONGARCH, elf.ELFDATA2LSB}, true
+	default:
+		return elfTarget{}, false
+	}
+}
+
+// archName returns the Go name of the architecture of an ELF target, or the
+// ELF machine name when Go does not know it.
+func archName(t elfTarget) string {
+	for _, arch := range []string{"amd64", "386", "arm64", "arm", "ppc64le", "ppc64", "s390x", "riscv64", "loong64"} {
+		if target, _ := elfTargetOf(arch); target == t {
+			return arch
+		}
+	}
+	return t.machine.String()
+}
+
+// libcOf returns the C library of an ELF interpreter path, or "" if unknown.
+func libcOf(interp string) string {
+	switch base := interp[strings.LastIndexByte(interp, '/')+1:]; {
+	case strings.HasPrefix(base, "ld-musl"):
+		return "musl"
+	case strings.HasPrefix(base, "ld-linux"), strings.HasPrefix(base, "ld64.so"):
+		return "glibc"
+	default:
+		return ""
+	}
+}
+
+// checkCompatible inspects the ELF header and the PT_INTERP of the executable
+// exe and returns why it cannot run on this host: another architecture, or a
+// dynamic loader missing here, as for a musl build on a glibc host. Files that
+// are not ELF executables, such as scripts, are assumed to run.
+func checkCompatible(exe string) error {
+	return checkCompatibleWith(exe, goruntime.GOARCH)
+}
+
+func checkCompatibleWith(exe, arch string) error {
+	f, err := elf.Open(exe)
+	if err != nil {
+		return nil //nolint:nilerr // not an ELF executable
+	}
+	defer f.Close()
+
+	target := elfTarget{f.Machine, f.Data}
+	if host, ok := elfTargetOf(arch); ok && target != host {
+		return fmt.Errorf("%s binary on %s host", archName(target), arch)
+	}
+	interp := interpreter(f)
+	if interp == "" {
+		return nil
+	}
+	if _, err = os.Stat(interp); err != nil {
+		if libc := libcOf(interp); libc != "" {
+			return fmt.Errorf("%s build, dynamic loader %s not found", libc, interp)
+		}
+		return fmt.Errorf("dynamic loader %s not found", interp)
+	}
+	return nil
+}
+
+// interpreter returns the PT_INTERP path of an ELF file, "" for a static one.
+func interpreter(f *elf.File) string {
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		data, err := io.ReadAll(p.Open())
+		if err != nil {
+			return ""
+		}
+		return string(bytes.TrimRight(data, "\x00"))
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package detect
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// writeELF writes a minimal ELF64 executable for target at path, with a
+// PT_INTERP of interp unless it is empty.
+func writeELF(t *testing.T, path string, target elfTarget, interp string) {
+	t.Helper()
+	var order binary.ByteOrder = binary.LittleEndian
+	if target.data == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	const ehsize, phentsize = 64, 56
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(target.machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Ehsize:    ehsize,
+		Phentsize: phentsize,
+		Shentsize: 64,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(target.data)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	var buf bytes.Buffer
+	if interp != "" {
+		hdr.Phoff = ehsize
+		hdr.Phnum = 1
+	}
+	if err := binary.Write(&buf, order, hdr); err != nil {
+		t.Fatal(err)
+	}
+	if interp != "" {
+		size := uint64(len(interp) + 1)
+		prog := elf.Prog64{
+			Type:   uint32(elf.PT_INTERP),
+			Flags:  uint32(elf.PF_R),
+			Off:    ehsize + phentsize,
+			Filesz: size,
+			Memsz:  size,
+			Align:  1,
+		}
+		if err := binary.Write(&buf, order, prog); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString(interp + "\x00")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// foreignArch returns an architecture other than the one of the host.
+func foreignArch() string {
+	if goruntime.GOARCH == "s390x" {
+		return "amd64"
+	}
+	return "s390x"
+}
+
+func TestCheckCompatible(t *testing.T) {
+	dir := t.TempDir()
+	amd64, _ := elfTargetOf("amd64")
+	loader := filepath.Join(dir, "ld-linux-x86-64.so.2")
+	if err := os.WriteFile(loader, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, interp, arch, want string
+	}{
+		{"glibc", loader, "amd64", ""},
+		{"static", "", "amd64", ""},
+		{"arm64 host", loader, "arm64", "amd64 binary on arm64 host"},
+		{"musl", "/nonexistent/lib/ld-musl-x86_64.so.1", "amd64",
+			"musl build, dynamic loader /nonexistent/lib/ld-musl-x86_64.so.1 not found"},
+		{"unknown loader", "/nonexistent/ld.so", "amd64", "dynamic loader /nonexistent/ld.so not found"},
+	}
+	for _, tt := range tests {
+		exe := filepath.Join(dir, tt.name)
+		writeELF(t, exe, amd64, tt.interp)
+		err := checkCompatibleWith(exe, tt.arch)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.want != "" && (err == nil || err.Error() != tt.want):
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// scripts and other files that are not ELF executables are assumed to run
+	script := filepath.Join(dir, "script")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCompatibleWith(script, "arm64"); err != nil {
+		t.Errorf("script: %v", err)
+	}
+}
+
+func TestResolve_SkipsIncompatible(t *testing.T) {
+	base := t.TempDir()
+	host, ok := elfTargetOf(goruntime.GOARCH)
+	if !ok {
+		t.Skipf("no ELF target for %s", goruntime.GOARCH)
+	}
+	foreign, _ := elfTargetOf(foreignArch())
+	loader := filepath.Join(base, "ld-linux.so.2")
+	if err := os.WriteFile(loader, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join(base, "jdk-17-a")
+	musl := filepath.Join(base, "jdk-17-b")
+	other := filepath.Join(base, "jdk-17-c")
+	writeELF(t, filepath.Join(good, "bin", "java"), host, loader)
+	writeELF(t, filepath.Join(musl, "bin", "java"), host, "/nonexistent/lib/ld-musl.so.1")
+	writeELF(t, filepath.Join(other, "bin", "java"), foreign, "")
+
+	cfg := &config.Config{}
+	cfg.Default.Runtimes = map[string]config.RuntimeSetting{
+		"java": {Version: "17", Paths: []string{filepath.Join(base, "jdk-17-*")}},
+	}
+	res, err := Resolve(cfg, "", "java")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path != good {
+		t.Errorf("path = %s, want %s", res.Path, good)
+	}
+	want := []Skipped{
+		{other, foreignArch() + " binary on " + goruntime.GOARCH + " host"},
+		{musl, "musl build, dynamic loader /nonexistent/lib/ld-musl.so.1 not found"},
+	}
+	if len(res.Skipped) != len(want) || res.Skipped[0] != want[0] || res.Skipped[1] != want[1] {
+		t.Errorf("skipped = %+v, want %+v", res.Skipped, want)
+	}
+
+	if err = os.RemoveAll(good); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Resolve(cfg, "", "java")
+	if err == nil {
+		t.Fatal("resolved without a compatible candidate")
+	}
+	for _, s := range want {
+		if !strings.Contains(err.Error(), s.String()) {
+			t.Errorf("error %q does not report %q", err, s)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package detect
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,13 +14,12 @@ func hasGlobMeta(s string) bool {
 	return strings.ContainsAny(s, "*?[")
 }
 
-func checkCandidate(cand, exe string) (string, bool) {
+func checkCandidate(skips *skipLog, cand string, exes ...string) (string, bool) {
 	resolved, evalErr := filepath.EvalSymlinks(cand)
 	if evalErr != nil || resolved == "" {
 		resolved = cand
 	}
-	candidateExe := filepath.Join(resolved, "bin", exe)
-	if _, statErr := os.Stat(candidateExe); statErr == nil {
+	if skips.usable(resolved, exes) {
 		return resolved, true
 	}
 	return "", false
@@ -37,14 +37,20 @@ func expandPath(p string) string {
 	return p
 }
 
+// ExpandPath expands a configured path used outside of the runtime detection,
+// such as venv.path, the same way as the detection paths.
+func ExpandPath(p string) string {
+	return expandPath(p)
+}
+
 // tryOverridePath checks the cfg.OverridePath (after expansion).
-// Returns the expanded path if bin/exe exists there.
-func tryOverridePath(cfg config.RuntimeSetting, exe string) (string, bool) {
+// Returns the expanded path if any of exes exists there.
+func tryOverridePath(skips *skipLog, cfg config.RuntimeSetting, exes ...string) (string, bool) {
 	if cfg.OverridePath == "" {
 		return "", false
 	}
 	p := expandPath(cfg.OverridePath)
-	if _, err := os.Stat(filepath.Join(p, "bin", exe)); err == nil {
+	if skips.usable(p, exes) {
 		return p, true
 	}
 	return "", false
@@ -52,7 +58,7 @@ func tryOverridePath(cfg config.RuntimeSetting, exe string) (string, bool) {
 
 // tryEnvVar checks the path stored in the environment variable cfg.EnvVar.
 // The raw value is expanded before checking.
-func tryEnvVar(cfg config.RuntimeSetting, exe string) (string, bool) {
+func tryEnvVar(skips *skipLog, cfg config.RuntimeSetting, exes ...string) (string, bool) {
 	if cfg.EnvVar == "" {
 		return "", false
 	}
@@ -62,14 +68,14 @@ func tryEnvVar(cfg config.RuntimeSetting, exe string) (string, bool) {
 	}
 	p := expandPath(raw)
 
-	if _, err := os.Stat(filepath.Join(p, "bin", exe)); err == nil {
+	if skips.usable(p, exes) {
 		return p, true
 	}
 	return "", false
 }
 
 // tryPaths iterates over cfg.Paths, expanding each pattern and performing a reverse-sorted glob.
-func tryPaths(cfg config.RuntimeSetting, exe string) (string, bool) {
+func tryPaths(skips *skipLog, cfg config.RuntimeSetting, exes ...string) (string, bool) {
 	for _, pat := range cfg.Paths {
 		base := expandPath(pat)
 
@@ -77,7 +83,7 @@ func tryPaths(cfg config.RuntimeSetting, exe string) (string, bool) {
 			cands, _ := filepath.Glob(base)
 			sort.Sort(sort.Reverse(sort.StringSlice(cands)))
 			for _, cand := range cands {
-				if p, ok := checkCandidate(cand, exe); ok {
+				if p, ok := checkCandidate(skips, cand, exes...); ok {
 					return p, true
 				}
 			}
@@ -85,7 +91,7 @@ func tryPaths(cfg config.RuntimeSetting, exe string) (string, bool) {
 		}
 
 		if _, statErr := os.Stat(base); statErr == nil {
-			if p, ok := checkCandidate(base, exe); ok {
+			if p, ok := checkCandidate(skips, base, exes...); ok {
 				return p, true
 			}
 			continue
@@ -95,7 +101,7 @@ func tryPaths(cfg config.RuntimeSetting, exe string) (string, bool) {
 		cands, _ := filepath.Glob(globPat)
 		sort.Sort(sort.Reverse(sort.StringSlice(cands)))
 		for _, cand := range cands {
-			if p, ok := checkCandidate(cand, exe); ok {
+			if p, ok := checkCandidate(skips, cand, exes...); ok {
 				return p, true
 			}
 		}
@@ -103,14 +109,108 @@ func tryPaths(cfg config.RuntimeSetting, exe string) (string, bool) {
 	return "", false
 }
 
-// detectPath applies the three strategies in order: override_path, env_var, and paths.
-// Returns the first valid installation directory or false if none found.
-func detectPath(cfg config.RuntimeSetting, exe string) (string, bool) {
-	if p, ok := tryOverridePath(cfg, exe); ok {
-		return p, true
+// alternativesDir holds the links of the alternatives system (update-alternatives
+// on Debian, alternatives on RHEL).
+const alternativesDir = "/etc/alternatives"
+
+// tryAlternatives resolves the links named after cfg.Alternatives, or the
+// executables, in the alternatives directory dir to an installation directory.
+func tryAlternatives(skips *skipLog, cfg config.RuntimeSetting, dir string, exes ...string) (string, bool) {
+	names := cfg.Alternatives
+	if len(names) == 0 {
+		for _, exe := range exes {
+			names = append(names, filepath.Base(exe))
+		}
 	}
-	if p, ok := tryEnvVar(cfg, exe); ok {
-		return p, true
+	for _, name := range names {
+		if p, ok := homeOfExecutable(filepath.Join(dir, name), exes...); ok && skips.usable(p, exes) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// tryWhich resolves the executables found on PATH to an installation directory.
+func tryWhich(skips *skipLog, exes ...string) (string, bool) {
+	for _, exe := range exes {
+		found, err := exec.LookPath(filepath.Base(exe))
+		if err != nil {
+			continue
+		}
+		if p, ok := homeOfExecutable(found, exes...); ok && skips.usable(p, exes) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// homeOfExecutable returns the installation directory of the executable at
+// path after resolving its symlinks: the directory above bin, or above jre/bin
+// when that is an installation as well, as for a JDK 8.
+func homeOfExecutable(path string, exes ...string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	home := filepath.Dir(resolved)
+	for _, exe := range exes {
+		if rel := exePath("", exe); strings.HasSuffix(resolved, string(filepath.Separator)+rel) {
+			home = strings.TrimSuffix(resolved, string(filepath.Separator)+rel)
+			break
+		}
+	}
+	if filepath.Base(home) == "bin" {
+		home = filepath.Dir(home)
+	}
+	if filepath.Base(home) == "jre" && hasExecutable(filepath.Dir(home), exes) {
+		home = filepath.Dir(home)
+	}
+	if home == "" || !hasExecutable(home, exes) {
+		return "", false
+	}
+	return home, true
+}
+
+// detectPath applies the strategies of cfg in order, override_path, env_var and
+// paths by default. Returns the first valid installation directory or false if none found,
+// recording the candidates skipped as incompatible with the host in skips.
+func detectPath(skips *skipLog, cfg config.RuntimeSetting, exes ...string) (string, bool) {
+	return detectStrategies(skips, cfg, strategiesOf(cfg), exes...)
+}
+
+// strategiesOf returns the strategies of cfg, the default ones when it sets none.
+func strategiesOf(cfg config.RuntimeSetting) []config.Strategy {
+	if len(cfg.Strategies) == 0 {
+		return config.DefaultStrategies()
 	}
-	return tryPaths(cfg, exe)
+	return cfg.Strategies
+}
+
+// detectStrategies is detectPath with the given strategies.
+func detectStrategies(
+	skips *skipLog,
+	cfg config.RuntimeSetting,
+	strategies []config.Strategy,
+	exes ...string,
+) (string, bool) {
+	for _, strategy := range strategies {
+		var p string
+		var ok bool
+		switch strategy {
+		case config.StrategyOverridePath:
+			p, ok = tryOverridePath(skips, cfg, exes...)
+		case config.StrategyEnvVar:
+			p, ok = tryEnvVar(skips, cfg, exes...)
+		case config.StrategyPaths:
+			p, ok = tryPaths(skips, cfg, exes...)
+		case config.StrategyAlternatives:
+			p, ok = tryAlternatives(skips, cfg, alternativesDir, exes...)
+		case config.StrategyWhich:
+			p, ok = tryWhich(skips, exes...)
+		}
+		if ok {
+			return p, true
+		}
+	}
+	return "", false
 }
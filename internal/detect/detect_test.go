@@ -46,13 +46,13 @@ func TestTryOverridePath(t *testing.T) {
 	valid := createRuntimeDir(t, tmp, "o1", "java")
 	rt := config.RuntimeSetting{OverridePath: valid}
 
-	p, ok := tryOverridePath(rt, "java")
+	p, ok := tryOverridePath(nil, rt, "java")
 	if !ok || p != valid {
 		t.Errorf("tryOverridePath = (%q, %v), want (%q, true)", p, ok, valid)
 	}
 
 	rt.OverridePath = ""
-	if p2, ok2 := tryOverridePath(rt, "java"); ok2 || p2 != "" {
+	if p2, ok2 := tryOverridePath(nil, rt, "java"); ok2 || p2 != "" {
 		t.Errorf("tryOverridePath(empty) = (%q, %v), want ('', false)", p2, ok2)
 	}
 }
@@ -63,17 +63,17 @@ func TestTryEnvVar(t *testing.T) {
 	rt := config.RuntimeSetting{EnvVar: "TEST_PY"}
 
 	os.Unsetenv("TEST_PY")
-	if p, ok := tryEnvVar(rt, "py"); ok || p != "" {
+	if p, ok := tryEnvVar(nil, rt, "py"); ok || p != "" {
 		t.Errorf("tryEnvVar not set = (%q, %v), want ('', false)", p, ok)
 	}
 
 	t.Setenv("TEST_PY", tmp)
-	if p, ok := tryEnvVar(rt, "py"); ok || p != "" {
+	if p, ok := tryEnvVar(nil, rt, "py"); ok || p != "" {
 		t.Errorf("tryEnvVar invalid = (%q, %v), want ('', false)", p, ok)
 	}
 
 	t.Setenv("TEST_PY", valid)
-	if p, ok := tryEnvVar(rt, "py"); !ok || p != valid {
+	if p, ok := tryEnvVar(nil, rt, "py"); !ok || p != valid {
 		t.Errorf("tryEnvVar valid = (%q, %v), want (%q, true)", p, ok, valid)
 	}
 }
@@ -84,14 +84,14 @@ func TestTryPaths(t *testing.T) {
 
 	// exact path
 	rtExact := config.RuntimeSetting{Paths: []string{a}}
-	if p, ok := tryPaths(rtExact, "sh"); !ok || p != a {
+	if p, ok := tryPaths(nil, rtExact, "sh"); !ok || p != a {
 		t.Errorf("tryPaths exact = (%q, %v), want (%q, true)", p, ok, a)
 	}
 
 	// glob pattern should match a and b, pick b first
 	pattern := filepath.Join(tmp, "*")
 	rtGlob := config.RuntimeSetting{Paths: []string{pattern}}
-	p, ok := tryPaths(rtGlob, "sh")
+	p, ok := tryPaths(nil, rtGlob, "sh")
 	if !ok {
 		t.Fatalf("tryPaths glob failed")
 	}
@@ -106,7 +106,7 @@ func TestTryPaths(t *testing.T) {
 
 	// no paths
 	rtEmpty := config.RuntimeSetting{Paths: nil}
-	if p2, ok2 := tryPaths(rtEmpty, "sh"); ok2 || p2 != "" {
+	if p2, ok2 := tryPaths(nil, rtEmpty, "sh"); ok2 || p2 != "" {
 		t.Errorf("tryPaths empty = (%q, %v), want ('', false)", p2, ok2)
 	}
 }
@@ -125,26 +125,26 @@ func TestDetectPath_Order(t *testing.T) {
 	t.Setenv("TEST_EXE", e)
 
 	// override wins
-	if got, _ := detectPath(rt, "exe"); got != o {
+	if got, _ := detectPath(nil, rt, "exe"); got != o {
 		t.Errorf("detectPath override = %q, want %q", got, o)
 	}
 
 	rt.OverridePath = ""
 	// env next
-	if got, _ := detectPath(rt, "exe"); got != e {
+	if got, _ := detectPath(nil, rt, "exe"); got != e {
 		t.Errorf("detectPath env = %q, want %q", got, e)
 	}
 
 	// path next
 	os.Unsetenv("TEST_EXE")
 	rt.EnvVar = ""
-	if got, _ := detectPath(rt, "exe"); got != p {
+	if got, _ := detectPath(nil, rt, "exe"); got != p {
 		t.Errorf("detectPath path = %q, want %q", got, p)
 	}
 
 	// empty
 	rt.Paths = nil
-	if got, ok := detectPath(rt, "exe"); ok {
+	if got, ok := detectPath(nil, rt, "exe"); ok {
 		t.Errorf("detectPath empty = (%q, %v), want ('', false)", got, ok)
 	}
 }
@@ -165,7 +165,7 @@ func TestTryPaths_ExactSymlinkResolves(t *testing.T) {
 	}
 
 	rt := config.RuntimeSetting{Paths: []string{link}}
-	got, ok := tryPaths(rt, "java")
+	got, ok := tryPaths(nil, rt, "java")
 	if !ok {
 		t.Fatalf("tryPaths symlink failed")
 	}
@@ -183,7 +183,7 @@ func TestTryPaths_ExactExistsButInvalid_NoGlobFallback(t *testing.T) {
 	valid := createRuntimeDir(t, tmp, "java-1.8.0-openjdk-1.8.0.412", "java")
 
 	rt := config.RuntimeSetting{Paths: []string{exact}}
-	if p, ok := tryPaths(rt, "java"); ok || p != "" {
+	if p, ok := tryPaths(nil, rt, "java"); ok || p != "" {
 		t.Errorf("expected no detection, got (%q, %v) with valid sibling %q", p, ok, valid)
 	}
 }
@@ -197,7 +197,7 @@ func TestTryPaths_PrefixWhenBaseNotExists_GlobsAndFinds(t *testing.T) {
 	_ = createRuntimeDir(t, tmp, "jdk-17.0.7", "java")
 
 	rt := config.RuntimeSetting{Paths: []string{prefix}}
-	got, ok := tryPaths(rt, "java")
+	got, ok := tryPaths(nil, rt, "java")
 	if !ok {
 		t.Fatalf("expected detection via prefix glob")
 	}
@@ -218,7 +218,7 @@ func TestTryPaths_GlobPatternKeptVerbatim(t *testing.T) {
 	pattern := filepath.Join(tmp, "liberica-jre-17*")
 	rt := config.RuntimeSetting{Paths: []string{pattern}}
 
-	got, ok := tryPaths(rt, "java")
+	got, ok := tryPaths(nil, rt, "java")
 	if !ok {
 		t.Fatalf("glob pattern failed")
 	}
@@ -240,7 +240,7 @@ func TestTryPaths_SymlinkGlob(t *testing.T) {
 	pattern := filepath.Join(tmp, "jre-21*")
 	rt := config.RuntimeSetting{Paths: []string{pattern}}
 
-	got, ok := tryPaths(rt, "java")
+	got, ok := tryPaths(nil, rt, "java")
 	if !ok {
 		t.Fatalf("symlink via glob failed")
 	}
@@ -258,8 +258,79 @@ func TestTryPaths_NoBinSkip(t *testing.T) {
 	good := createRuntimeDir(t, tmp, "jdk-23.0.1", "java")
 
 	rt := config.RuntimeSetting{Paths: []string{filepath.Join(tmp, "jdk-23*")}}
-	got, ok := tryPaths(rt, "java")
+	got, ok := tryPaths(nil, rt, "java")
 	if !ok || got != good {
 		t.Errorf("got (%q,%v), want (%q,true)", got, ok, good)
 	}
 }
+
+func TestTryAlternatives(t *testing.T) {
+	tmp := t.TempDir()
+	// a JDK 8 with java in bin and jre/bin, the alternative pointing to the jre one
+	jdk := createRuntimeDir(t, tmp, "java-1.8.0-openjdk", "java")
+	jreBin := filepath.Join(jdk, "jre", "bin")
+	if err := os.MkdirAll(jreBin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(jreBin, "java"), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	alternatives := filepath.Join(tmp, "alternatives")
+	if err := os.MkdirAll(alternatives, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(jreBin, "java"), filepath.Join(alternatives, "java")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := tryAlternatives(nil, config.RuntimeSetting{}, alternatives, "java"); !ok || got != jdk {
+		t.Errorf("tryAlternatives = (%q, %v), want (%q, true)", got, ok, jdk)
+	}
+	rt := config.RuntimeSetting{Alternatives: []string{"jre"}}
+	if got, ok := tryAlternatives(nil, rt, alternatives, "java"); ok {
+		t.Errorf("tryAlternatives without the link = (%q, %v)", got, ok)
+	}
+
+	// python3 -> python3.11 in /usr/bin
+	usr := createRuntimeDir(t, tmp, "usr", "python3.11")
+	if err := os.Symlink(filepath.Join(usr, "bin", "python3.11"), filepath.Join(alternatives, "python3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("python3.11", filepath.Join(usr, "bin", "python3")); err != nil {
+		t.Fatal(err)
+	}
+	rt = config.RuntimeSetting{Alternatives: []string{"python3"}}
+	if got, ok := tryAlternatives(nil, rt, alternatives, "python", "python3"); !ok || got != usr {
+		t.Errorf("tryAlternatives python3 = (%q, %v), want (%q, true)", got, ok, usr)
+	}
+}
+
+func TestDetectPath_Strategies(t *testing.T) {
+	tmp := t.TempDir()
+	onPath := createRuntimeDir(t, tmp, "node-20", "node")
+	inPaths := createRuntimeDir(t, tmp, "node-18", "node")
+	binDir := filepath.Join(tmp, "usr-bin")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(onPath, "bin", "node"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(onPath, "bin", "node"), filepath.Join(binDir, "node")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir)
+
+	rt := config.RuntimeSetting{Paths: []string{inPaths}}
+	if got, _ := detectPath(nil, rt, "node"); got != inPaths {
+		t.Errorf("default strategies = %q, want %q", got, inPaths)
+	}
+	rt.Strategies = []config.Strategy{config.StrategyWhich, config.StrategyPaths}
+	if got, _ := detectPath(nil, rt, "node"); got != onPath {
+		t.Errorf("which first = %q, want %q", got, onPath)
+	}
+	rt.Strategies = []config.Strategy{config.StrategyOverridePath}
+	if got, ok := detectPath(nil, rt, "node"); ok {
+		t.Errorf("override_path only = %q, want none", got)
+	}
+}
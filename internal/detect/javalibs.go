@@ -0,0 +1,128 @@
+package detect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const (
+	JavaNativePathEnv = "JAVA_NATIVE_PATH"
+	javaNativeLibsEnv = "JAVA_NATIVE_LIBS"
+	defaultNativeLib  = "libjvm.so"
+)
+
+// ErrNativeLibsNotFound is returned when none of the requested libraries exist under JAVA_HOME.
+var ErrNativeLibsNotFound = errors.New("native java libraries not found")
+
+// ResolveJavaNativePath returns the value for JAVA_NATIVE_PATH.
+// Search order:
+//  1. native_path of the service java runtime, then of the default java runtime
+//  2. JAVA_NATIVE_PATH environment variable
+//  3. JAVA_NATIVE_PATH in the bigtop-utils defaults file
+//  4. directories under javaHome containing any of the configured native_libs
+func ResolveJavaNativePath(cfg *config.Config, service, javaHome string) (string, error) {
+	if p := nativePathOverride(cfg, service); p != "" {
+		return p, nil
+	}
+	if p := os.Getenv(JavaNativePathEnv); p != "" {
+		return p, nil
+	}
+	if p := cfg.BigtopDefaults[JavaNativePathEnv]; p != "" {
+		return p, nil
+	}
+	if javaHome == "" {
+		return "", errors.New("JAVA_HOME is empty")
+	}
+	libs := nativeLibNames(cfg, service)
+	dirs := FindNativeLibDirs(javaHome, libs)
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("%w: %s in %s", ErrNativeLibsNotFound, strings.Join(libs, ", "), javaHome)
+	}
+	return strings.Join(dirs, string(os.PathListSeparator)), nil
+}
+
+func nativePathOverride(cfg *config.Config, service string) string {
+	if svc, ok := cfg.Services[service]; ok && service != "" {
+		if p := svc.Runtimes["java"].NativePath; p != "" {
+			return expandPath(p)
+		}
+	}
+	if p := cfg.Default.Runtimes["java"].NativePath; p != "" {
+		return expandPath(p)
+	}
+	return ""
+}
+
+// nativeLibNames returns the library names to look for: native_libs of the service,
+// then of the default java runtime, then JAVA_NATIVE_LIBS, then libjvm.so.
+func nativeLibNames(cfg *config.Config, service string) []string {
+	if svc, ok := cfg.Services[service]; ok && service != "" {
+		if libs := svc.Runtimes["java"].NativeLibs; len(libs) > 0 {
+			return libs
+		}
+	}
+	if libs := cfg.Default.Runtimes["java"].NativeLibs; len(libs) > 0 {
+		return libs
+	}
+	if libs := strings.Fields(os.Getenv(javaNativeLibsEnv)); len(libs) > 0 {
+		return libs
+	}
+	return []string{defaultNativeLib}
+}
+
+// FindNativeLibDirs walks root, following symlinks like `find -L`, and returns
+// the deduplicated list of directories containing any of libs in reverse sorted
+// order, the order bigtop-detect-javalibs built JAVA_NATIVE_PATH in by
+// prepending each directory of `sort -u`.
+func FindNativeLibDirs(root string, libs []string) []string {
+	names := make(map[string]bool, len(libs))
+	for _, l := range libs {
+		names[l] = true
+	}
+	found := make(map[string]bool)
+	walkFollowingSymlinks(root, make(map[string]bool), func(path string) {
+		if names[filepath.Base(path)] {
+			found[filepath.Dir(path)] = true
+		}
+	})
+	dirs := make([]string, 0, len(found))
+	for d := range found {
+		dirs = append(dirs, d)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	return dirs
+}
+
+// walkFollowingSymlinks calls fn for every non-directory entry under dir.
+// Symlinked directories are descended into; visited keeps track of resolved
+// directories to break symlink loops.
+func walkFollowingSymlinks(dir string, visited map[string]bool, fn func(path string)) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil || visited[resolved] {
+		return
+	}
+	visited[resolved] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		st, statErr := os.Stat(p)
+		if statErr != nil {
+			continue
+		}
+		if st.IsDir() {
+			walkFollowingSymlinks(p, visited, fn)
+			continue
+		}
+		fn(p)
+	}
+}
@@ -0,0 +1,98 @@
+package detect
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestFindNativeLibDirs_ReverseSortedAndDeduplicated(t *testing.T) {
+	javaHome := t.TempDir()
+	server := filepath.Join(javaHome, "lib", "server")
+	amd64 := filepath.Join(javaHome, "jre", "lib", "amd64")
+	mustWriteFile(t, filepath.Join(server, "libjvm.so"), nil)
+	mustWriteFile(t, filepath.Join(server, "libjava.so"), nil)
+	mustWriteFile(t, filepath.Join(amd64, "libjava.so"), nil)
+	mustWriteFile(t, filepath.Join(javaHome, "lib", "libnet.so"), nil)
+
+	got := FindNativeLibDirs(javaHome, []string{"libjvm.so", "libjava.so"})
+	// the order of the original script, which prepended each directory
+	want := []string{server, amd64}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFindNativeLibDirs_FollowsSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	target := filepath.Join(tmp, "target")
+	mustWriteFile(t, filepath.Join(target, "server", "libjvm.so"), nil)
+
+	javaHome := filepath.Join(tmp, "jdk")
+	mustSymlink(t, target, filepath.Join(javaHome, "lib"))
+	// loop back to the java home must not hang the walk
+	mustSymlink(t, javaHome, filepath.Join(target, "loop"))
+
+	got := FindNativeLibDirs(javaHome, []string{"libjvm.so"})
+	want := []string{filepath.Join(javaHome, "lib", "server")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveJavaNativePath_Order(t *testing.T) {
+	javaHome := t.TempDir()
+	server := filepath.Join(javaHome, "lib", "server")
+	mustWriteFile(t, filepath.Join(server, "libjvm.so"), nil)
+
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"svc": {Runtimes: map[string]config.RuntimeSetting{"java": {NativePath: "/opt/native"}}},
+	}}
+	t.Setenv(JavaNativePathEnv, "/env/native")
+	t.Setenv(javaNativeLibsEnv, "")
+
+	// service config wins
+	if got, err := ResolveJavaNativePath(cfg, "svc", javaHome); err != nil || got != "/opt/native" {
+		t.Errorf("config override = (%q, %v), want /opt/native", got, err)
+	}
+
+	// environment next
+	if got, err := ResolveJavaNativePath(cfg, "", javaHome); err != nil || got != "/env/native" {
+		t.Errorf("env override = (%q, %v), want /env/native", got, err)
+	}
+
+	// detection last
+	os.Unsetenv(JavaNativePathEnv)
+	if got, err := ResolveJavaNativePath(cfg, "", javaHome); err != nil || got != server {
+		t.Errorf("detection = (%q, %v), want %q", got, err, server)
+	}
+}
+
+func TestResolveJavaNativePath_ConfiguredLibs(t *testing.T) {
+	t.Setenv(JavaNativePathEnv, "")
+	javaHome := t.TempDir()
+	mustWriteFile(t, filepath.Join(javaHome, "lib", "server", "libjvm.so"), nil)
+	hadoop := filepath.Join(javaHome, "native")
+	mustWriteFile(t, filepath.Join(hadoop, "libhadoop.so"), nil)
+
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"svc": {Runtimes: map[string]config.RuntimeSetting{"java": {NativeLibs: []string{"libhadoop.so"}}}},
+	}}
+	got, err := ResolveJavaNativePath(cfg, "svc", javaHome)
+	if err != nil || got != hadoop {
+		t.Errorf("got (%q, %v), want %q", got, err, hadoop)
+	}
+}
+
+func TestResolveJavaNativePath_NotFound(t *testing.T) {
+	t.Setenv(JavaNativePathEnv, "")
+	t.Setenv(javaNativeLibsEnv, "")
+	_, err := ResolveJavaNativePath(&config.Config{}, "", t.TempDir())
+	if !errors.Is(err, ErrNativeLibsNotFound) {
+		t.Fatalf("err = %v, want ErrNativeLibsNotFound", err)
+	}
+}
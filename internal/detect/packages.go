@@ -0,0 +1,243 @@
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// Package database sources, see Resolution.
+const (
+	SourceDpkg = "dpkg"
+	SourceRPM  = "rpm"
+)
+
+const (
+	dpkgDir         = "/var/lib/dpkg"
+	rpmDBDir        = "/var/lib/rpm"
+	rpmQueryTimeout = 10 * time.Second
+	// packageFields is the number of tab-separated fields of an rpm query line.
+	packageFields = 3
+)
+
+// Package is a runtime installation found in a package database.
+type Package struct {
+	Source  string
+	Name    string
+	Version string
+	Home    string
+}
+
+// packageFiles is an installed package with the files it owns.
+type packageFiles struct {
+	name    string
+	version string
+	files   []string
+}
+
+// packageHints returns the substrings of the names of the packages that may
+// contain the runtime, so that only their file lists are read.
+func packageHints(rt string) []string {
+	switch rt {
+	case "java":
+		return []string{"jdk", "jre", "java"}
+	default:
+		return []string{rt}
+	}
+}
+
+func hinted(name string, hints []string) bool {
+	for _, h := range hints {
+		if strings.Contains(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// InstalledPackages returns the installations of the runtime owned by packages
+// installed with dpkg or rpm, the newest version first, or nil unless
+// package_databases.enabled is set. The package databases are read locally,
+// no repository is queried.
+func InstalledPackages(cfg *config.Config, rt string) []Package {
+	if !cfg.PackageDatabases.Enabled {
+		return nil
+	}
+	exes := executables(Definition(cfg, rt))
+	hints := packageHints(rt)
+	pkgs := packageHomes(SourceDpkg, dpkgPackages(dpkgDir, hints), exes)
+	return append(pkgs, packageHomes(SourceRPM, rpmPackages(hints), exes)...)
+}
+
+// packageHomes returns the installation directories of the executables owned by
+// the packages, the newest version first, each directory once.
+func packageHomes(source string, pkgs []packageFiles, exes []string) []Package {
+	var out []Package
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.files {
+			if !ownsExecutable(file, exes) {
+				continue
+			}
+			home, ok := homeOfExecutable(file, exes...)
+			if !ok || seen[home] {
+				continue
+			}
+			seen[home] = true
+			out = append(out, Package{Source: source, Name: pkg.name, Version: pkg.version, Home: home})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return compareVersions(packageVersion(out[i].Version), packageVersion(out[j].Version)) > 0
+	})
+	return out
+}
+
+// ownsExecutable reports whether file is one of the executables of an installation.
+func ownsExecutable(file string, exes []string) bool {
+	for _, exe := range exes {
+		if strings.HasSuffix(file, string(filepath.Separator)+exePath("", exe)) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageVersion returns the upstream version of a package version without its
+// epoch and release, with the legacy 1.x of Java 8 and older given as x:
+// 1:1.8.0.392.b08-4.el8 gives 8.0.392.
+func packageVersion(v string) string {
+	if _, rest, ok := strings.Cut(v, ":"); ok {
+		v = rest
+	}
+	return managedInstall{name: v}.version()
+}
+
+// dpkgPackages returns the installed packages of the dpkg database in dir whose
+// names contain one of hints, with the files listed in info/<name>.list.
+func dpkgPackages(dir string, hints []string) []packageFiles {
+	data, err := os.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return nil
+	}
+	var pkgs []packageFiles
+	for _, stanza := range strings.Split(string(data), "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(stanza, "\n") {
+			if name, value, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, " ") {
+				fields[name] = strings.TrimSpace(value)
+			}
+		}
+		name := fields["Package"]
+		if name == "" || !strings.HasSuffix(fields["Status"], " installed") || !hinted(name, hints) {
+			continue
+		}
+		// multi-arch packages list their files in <name>:<arch>.list
+		files := readLines(filepath.Join(dir, "info", name+".list"))
+		if files == nil {
+			files = readLines(filepath.Join(dir, "info", name+":"+fields["Architecture"]+".list"))
+		}
+		pkgs = append(pkgs, packageFiles{name: name, version: fields["Version"], files: files})
+	}
+	return pkgs
+}
+
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// rpmPackages returns the installed packages of the rpm database whose names
+// contain one of hints, with their files, or nil when rpm is not available.
+func rpmPackages(hints []string) []packageFiles {
+	if _, err := exec.LookPath("rpm"); err != nil {
+		return nil
+	}
+	names, err := rpmQuery("-qa", "--queryformat", `%{NAME}\n`)
+	if err != nil {
+		return nil
+	}
+	var matching []string
+	for _, name := range strings.Fields(names) {
+		if hinted(name, hints) {
+			matching = append(matching, name)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+	// the epoch is not part of %{VERSION}
+	args := append([]string{"-q", "--queryformat", `[%{NAME}\t%{VERSION}\t%{FILENAMES}\n]`}, matching...)
+	out, err := rpmQuery(args...)
+	if err != nil {
+		return nil
+	}
+	return parseRPMFiles(out)
+}
+
+func rpmQuery(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpmQueryTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "rpm", args...).Output()
+	return string(out), err
+}
+
+// parseRPMFiles parses the name, version and file lines of an rpm query.
+func parseRPMFiles(out string) []packageFiles {
+	var pkgs []packageFiles
+	index := make(map[string]int)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", packageFields)
+		if len(fields) != packageFields {
+			continue
+		}
+		i, ok := index[fields[0]]
+		if !ok {
+			i = len(pkgs)
+			index[fields[0]] = i
+			pkgs = append(pkgs, packageFiles{name: fields[0], version: fields[1]})
+		}
+		pkgs[i].files = append(pkgs[i].files, fields[2])
+	}
+	return pkgs
+}
+
+// detectPackages returns the newest installation of the runtime version owned
+// by an installed package.
+func detectPackages(skips *skipLog, cfg *config.Config, runtime, version string, exes []string) (Resolution, bool) {
+	for _, pkg := range InstalledPackages(cfg, runtime) {
+		if matchesVersion(packageVersion(pkg.Version), version) && skips.usable(pkg.Home, exes) {
+			return Resolution{Path: pkg.Home, Source: pkg.Source}, true
+		}
+	}
+	return Resolution{}, false
+}
+
+// packageDatabaseFiles returns the files changed when packages are installed or removed.
+func packageDatabaseFiles() []string {
+	return []string{
+		filepath.Join(dpkgDir, "status"),
+		rpmDBDir,
+		filepath.Join(rpmDBDir, "rpmdb.sqlite"),
+		filepath.Join(rpmDBDir, "Packages"),
+		filepath.Join(rpmDBDir, "Packages.db"),
+	}
+}
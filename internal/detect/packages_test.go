@@ -0,0 +1,134 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestPackageVersion(t *testing.T) {
+	tests := map[string]string{
+		"17.0.9+9-1~deb12u1":    "17.0.9",
+		"1:1.8.0.392.b08-4.el8": "8.0.392",
+		"8u392-ga-1":            "8",
+		"3.11.2-1+b1":           "3.11.2",
+		"11.0.21.0.9-2.el9":     "11.0.21.0.9",
+	}
+	for in, want := range tests {
+		if got := packageVersion(in); got != want {
+			t.Errorf("packageVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDpkgPackages(t *testing.T) {
+	root := t.TempDir()
+	jdk17 := makeDir(t, root, "usr/lib/jvm/java-17-openjdk-amd64", "java")
+	jdk8 := makeDir(t, root, "usr/lib/jvm/java-8-openjdk-amd64", "java")
+	jre8 := filepath.Join(jdk8, "jre", "bin")
+	if err := os.MkdirAll(jre8, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(jre8, "java"), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	removed := makeDir(t, root, "usr/lib/jvm/java-11-openjdk-amd64", "java")
+
+	db := filepath.Join(root, "var/lib/dpkg")
+	if err := os.MkdirAll(filepath.Join(db, "info"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	status := `Package: openjdk-17-jre-headless
+Status: install ok installed
+Architecture: amd64
+Multi-Arch: same
+Version: 17.0.9+9-1~deb12u1
+Description: OpenJDK Java runtime
+ multi-line description: with a colon
+
+Package: openjdk-8-jre-headless
+Status: install ok installed
+Architecture: amd64
+Version: 8u392-ga-1
+
+Package: openjdk-11-jre-headless
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 11.0.21+9-1
+
+Package: libc6
+Status: install ok installed
+Version: 2.36-9
+`
+	files := map[string][]string{
+		"openjdk-17-jre-headless:amd64.list": {"/usr/lib/jvm", jdk17, filepath.Join(jdk17, "bin", "java")},
+		"openjdk-8-jre-headless.list":        {filepath.Join(jre8, "java"), filepath.Join(jdk8, "bin", "java")},
+		"openjdk-11-jre-headless.list":       {filepath.Join(removed, "bin", "java")},
+	}
+	if err := os.WriteFile(filepath.Join(db, "status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, lines := range files {
+		if err := os.WriteFile(filepath.Join(db, "info", name), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkgs := packageHomes(SourceDpkg, dpkgPackages(db, packageHints("java")), []string{"java"})
+	if len(pkgs) != 2 {
+		t.Fatalf("packages = %+v, want 2", pkgs)
+	}
+	if pkgs[0].Home != jdk17 || pkgs[0].Name != "openjdk-17-jre-headless" || pkgs[0].Source != SourceDpkg {
+		t.Errorf("newest = %+v", pkgs[0])
+	}
+	// the jre of the JDK 8 is the same installation as the JDK
+	if pkgs[1].Home != jdk8 || pkgs[1].Version != "8u392-ga-1" {
+		t.Errorf("java 8 = %+v", pkgs[1])
+	}
+}
+
+func TestRPMPackages(t *testing.T) {
+	root := t.TempDir()
+	jdk := makeDir(t, root, "usr/lib/jvm/java-17-openjdk-17.0.9.0.9-2.el8.x86_64", "java")
+	python := makeDir(t, root, "usr", "python3")
+
+	// a fake rpm answering the two queries
+	bin := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+-qa) printf 'java-17-openjdk-headless\nbash\npython3\n' ;;
+-q) printf 'java-17-openjdk-headless\t17.0.9.0.9\t` + jdk + `\n'
+    printf 'java-17-openjdk-headless\t17.0.9.0.9\t` + filepath.Join(jdk, "bin", "java") + `\n'
+    printf 'python3\t3.6.8\t` + filepath.Join(python, "bin", "python3") + `\n' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "rpm"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	pkgs := packageHomes(SourceRPM, rpmPackages(packageHints("java")), []string{"java"})
+	if len(pkgs) != 1 || pkgs[0].Home != jdk || pkgs[0].Version != "17.0.9.0.9" {
+		t.Errorf("java packages = %+v", pkgs)
+	}
+	pkgs = packageHomes(SourceRPM, rpmPackages(packageHints("python")), []string{"python", "python3"})
+	if len(pkgs) != 1 || pkgs[0].Home != python || pkgs[0].Name != "python3" {
+		t.Errorf("python packages = %+v", pkgs)
+	}
+
+	cfg := &config.Config{}
+	if pkgs = InstalledPackages(cfg, "java"); pkgs != nil {
+		t.Errorf("package databases read without package_databases.enabled: %+v", pkgs)
+	}
+	cfg.PackageDatabases.Enabled = true
+	found := false
+	for _, pkg := range InstalledPackages(cfg, "java") {
+		found = found || pkg.Source == SourceRPM && pkg.Home == jdk
+	}
+	if !found {
+		t.Errorf("rpm package of %s not found with package_databases.enabled", jdk)
+	}
+}
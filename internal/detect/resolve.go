@@ -2,34 +2,70 @@ package detect
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/arenadata/ad-runtime-utils/internal/config"
 )
 
-func exeName(rt string) string {
+func serviceRuntime(cfg *config.Config, service, runtime string) (config.RuntimeSetting, bool) {
+	if service == "" {
+		return config.RuntimeSetting{}, false
+	}
+	svcCfg, ok := cfg.Services[service]
+	if !ok {
+		return config.RuntimeSetting{}, false
+	}
+	rtCfg, ok := svcCfg.Runtimes[runtime]
+	return rtCfg, ok
+}
+
+// detectServiceOverride applies the override_path of the service runtime, when
+// it is one of its strategies. It comes before the bigtop-utils defaults.
+func detectServiceOverride(skips *skipLog, cfg *config.Config, service, runtime string, exes []string) (string, bool) {
+	rtCfg, ok := serviceRuntime(cfg, service, runtime)
+	if !ok || !slices.Contains(strategiesOf(rtCfg), config.StrategyOverridePath) {
+		return "", false
+	}
+	return tryOverridePath(skips, rtCfg, exes...)
+}
+
+// detectServiceLevel applies the other strategies of the service runtime, which
+// come after the bigtop-utils defaults.
+func detectServiceLevel(skips *skipLog, cfg *config.Config, service, runtime string, exes []string) (string, bool) {
+	rtCfg, ok := serviceRuntime(cfg, service, runtime)
+	if !ok {
+		return "", false
+	}
+	strategies := slices.DeleteFunc(slices.Clone(strategiesOf(rtCfg)), func(s config.Strategy) bool {
+		return s == config.StrategyOverridePath
+	})
+	return detectStrategies(skips, rtCfg, strategies, exes...)
+}
+
+// bigtopEnvName returns the bigtop-utils defaults variable overriding the runtime.
+func bigtopEnvName(rt string) string {
 	switch rt {
 	case "java":
-		return "java"
-	case "python":
-		return "python"
+		return "JAVA_HOME"
 	default:
-		return rt
+		return ""
 	}
 }
 
-func detectServiceLevel(cfg *config.Config, service, runtime, exe string) (string, bool) {
-	if service == "" {
+func detectBigtopDefaults(skips *skipLog, cfg *config.Config, runtime string, exes []string) (string, bool) {
+	name := bigtopEnvName(runtime)
+	if name == "" {
 		return "", false
 	}
-	svcCfg, ok := cfg.Services[service]
-	if !ok {
+	raw := cfg.BigtopDefaults[name]
+	if raw == "" {
 		return "", false
 	}
-	rtCfg, ok := svcCfg.Runtimes[runtime]
-	if !ok {
-		return "", false
+	p := expandPath(raw)
+	if skips.usable(p, exes) {
+		return p, true
 	}
-	return detectPath(rtCfg, exe)
+	return "", false
 }
 
 func detectVersion(cfg *config.Config, service, runtime string) (string, error) {
@@ -50,44 +86,127 @@ func detectVersion(cfg *config.Config, service, runtime string) (string, error)
 	return ver, nil
 }
 
-func detectAutodetectVersion(cfg *config.Config, runtime, version, exe string) (string, bool) {
+func detectAutodetectVersion(
+	skips *skipLog,
+	cfg *config.Config,
+	runtime, version string,
+	exes []string,
+) (string, bool) {
 	if versions, ok := cfg.Autodetect.Runtimes[runtime]; ok {
 		if verCfg, ok2 := versions[version]; ok2 {
-			return detectPath(verCfg, exe)
+			return detectPath(skips, verCfg, exes...)
 		}
 	}
 	return "", false
 }
 
-func detectDefault(cfg *config.Config, runtime, exe string) (string, bool) {
+func detectDefault(skips *skipLog, cfg *config.Config, runtime string, exes []string) (string, bool) {
 	if defCfg, ok := cfg.Default.Runtimes[runtime]; ok {
-		return detectPath(defCfg, exe)
+		return detectPath(skips, defCfg, exes...)
 	}
 	return "", false
 }
 
+// Resolution sources.
+const (
+	SourceService    = "service"
+	SourceBigtop     = "bigtop-utils"
+	SourceAutodetect = "autodetect"
+	SourceDefault    = "default"
+)
+
+// Resolution is a detected runtime installation together with the step of the
+// resolution chain it came from (one of the Source* constants) and the
+// candidates skipped before it as incompatible with the host.
+type Resolution struct {
+	Path    string
+	Source  string
+	Skipped []Skipped
+}
+
+// ResolveRuntime returns the installation directory of the runtime for the service.
 func ResolveRuntime(cfg *config.Config, service, runtime string) (string, error) {
-	exe := exeName(runtime)
+	res, err := Resolve(cfg, service, runtime)
+	return res.Path, err
+}
 
-	// 1) Service-level detection
-	if path, ok := detectServiceLevel(cfg, service, runtime, exe); ok {
-		return path, nil
+// Resolve is like ResolveRuntime, but also reports where the path came from.
+// Candidates whose executables cannot run on this host are skipped and listed
+// in the Resolution, or in the error when no installation is found.
+func Resolve(cfg *config.Config, service, runtime string) (Resolution, error) {
+	skips := &skipLog{}
+	res, err := resolve(skips, cfg, service, runtime)
+	res.Skipped = skips.list()
+	return res, err
+}
+
+func resolve(skips *skipLog, cfg *config.Config, service, runtime string) (Resolution, error) {
+	exes := executables(Definition(cfg, runtime))
+
+	// 1) Service override_path
+	if path, ok := detectServiceOverride(skips, cfg, service, runtime, exes); ok {
+		return Resolution{Path: path, Source: SourceService}, nil
+	}
+
+	// 2) bigtop-utils defaults (e.g. JAVA_HOME in /etc/default/bigtop-utils)
+	if path, ok := detectBigtopDefaults(skips, cfg, runtime, exes); ok {
+		return Resolution{Path: path, Source: SourceBigtop}, nil
+	}
+
+	// 2b) The other service-level strategies: env_var, paths...
+	if path, ok := detectServiceLevel(skips, cfg, service, runtime, exes); ok {
+		return Resolution{Path: path, Source: SourceService}, nil
 	}
 
-	// 2) Determine version
+	// 3) Determine version
 	version, err := detectVersion(cfg, service, runtime)
 	if err != nil {
-		return "", err
+		return Resolution{}, err
 	}
 
-	// 3) Autodetect per-version
-	if path, ok := detectAutodetectVersion(cfg, runtime, version, exe); ok {
-		return path, nil
+	// 4) Autodetect per-version
+	if path, ok := detectAutodetectVersion(skips, cfg, runtime, version, exes); ok {
+		return Resolution{Path: path, Source: SourceAutodetect}, nil
+	}
+
+	// 4b) Version managers of the user (SDKMAN, asdf, jenv, pyenv)
+	if res, ok := detectVersionManagers(skips, cfg, runtime, version, exes); ok {
+		return res, nil
+	}
+
+	// 4c) Installed dpkg or rpm packages
+	if res, ok := detectPackages(skips, cfg, runtime, version, exes); ok {
+		return res, nil
+	}
+
+	// 5) Default fallback
+	if path, ok := detectDefault(skips, cfg, runtime, exes); ok {
+		return Resolution{Path: path, Source: SourceDefault}, nil
 	}
+	return Resolution{}, skips.notFound(
+		"could not detect runtime '%s' for service '%s' (version '%s')", runtime, service, version)
+}
 
-	// 4) Default fallback
-	if path, ok := detectDefault(cfg, runtime, exe); ok {
+// ResolveRuntimeVersion resolves a specific version of the runtime through
+// autodetect.runtimes.<runtime>.<version>, the version managers and the installed
+// packages, falling back to default.runtimes.<runtime> when its version matches.
+// It is used to find base interpreters independent of services.
+func ResolveRuntimeVersion(cfg *config.Config, runtime, version string) (string, error) {
+	exes := executables(Definition(cfg, runtime))
+	skips := &skipLog{}
+	if path, ok := detectAutodetectVersion(skips, cfg, runtime, version, exes); ok {
 		return path, nil
 	}
-	return "", fmt.Errorf("could not detect runtime '%s' for service '%s' (version '%s')", runtime, service, version)
+	if res, ok := detectVersionManagers(skips, cfg, runtime, version, exes); ok {
+		return res.Path, nil
+	}
+	if res, ok := detectPackages(skips, cfg, runtime, version, exes); ok {
+		return res.Path, nil
+	}
+	if cfg.Default.Runtimes[runtime].Version == version {
+		if path, ok := detectDefault(skips, cfg, runtime, exes); ok {
+			return path, nil
+		}
+	}
+	return "", skips.notFound("could not detect runtime '%s' version '%s'", runtime, version)
 }
@@ -124,3 +124,66 @@ services:
 		})
 	}
 }
+
+func TestResolve_BigtopDefaults(t *testing.T) {
+	base := t.TempDir()
+	bigtopJava := makeDir(t, base, "jdk-bigtop", "java")
+	svcJava := makeDir(t, base, "jdk-svc", "java")
+	autoJava := makeDir(t, base, "jdk8", "java")
+
+	cfg, err := config.Load(writeYAML(t, `
+bigtop_utils:
+  disabled: true
+default:
+  runtimes:
+    java:
+      version: "8"
+autodetect:
+  runtimes:
+    java:
+      "8":
+        paths:
+          - "`+autoJava+`"
+services:
+  svc:
+    runtimes:
+      java:
+        version: "8"
+        override_path: "`+svcJava+`"
+  globbed:
+    runtimes:
+      java:
+        version: "8"
+        paths:
+          - "`+filepath.Join(base, "jdk-")+`"
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.BigtopDefaults = map[string]string{"JAVA_HOME": bigtopJava}
+
+	// bigtop-utils defaults win over autodetect
+	res, err := Resolve(cfg, "", "java")
+	if err != nil || res.Path != bigtopJava || res.Source != SourceBigtop {
+		t.Errorf("default flow = (%+v, %v), want %q from bigtop-utils", res, err, bigtopJava)
+	}
+
+	// service override_path wins over bigtop-utils defaults
+	res, err = Resolve(cfg, "svc", "java")
+	if err != nil || res.Path != svcJava || res.Source != SourceService {
+		t.Errorf("service flow = (%+v, %v), want %q from service", res, err, svcJava)
+	}
+
+	// bigtop-utils defaults win over the service paths, which match jdk-svc first
+	res, err = Resolve(cfg, "globbed", "java")
+	if err != nil || res.Path != bigtopJava || res.Source != SourceBigtop {
+		t.Errorf("service paths = (%+v, %v), want %q from bigtop-utils", res, err, bigtopJava)
+	}
+
+	// invalid bigtop-utils JAVA_HOME is skipped
+	cfg.BigtopDefaults["JAVA_HOME"] = filepath.Join(base, "missing")
+	res, err = Resolve(cfg, "", "java")
+	if err != nil || res.Path != autoJava || res.Source != SourceAutodetect {
+		t.Errorf("fallback = (%+v, %v), want %q from autodetect", res, err, autoJava)
+	}
+}
@@ -0,0 +1,197 @@
+package detect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const (
+	defaultBinDir       = "bin"
+	homePlaceholder     = "home"
+	versionProbeTimeout = 10 * time.Second
+)
+
+// builtinDefinition returns the definitions of the runtimes known without configuration.
+func builtinDefinition(rt string) config.RuntimeDefinition {
+	switch rt {
+	case "java":
+		return config.RuntimeDefinition{
+			Executables: []string{"java"},
+			BinDir:      defaultBinDir,
+			EnvVars:     []string{"JAVA_HOME"},
+			VersionProbe: &config.VersionProbe{
+				Args:  []string{"-version"},
+				Regex: `version "([^"]+)"`,
+			},
+		}
+	case "python":
+		return config.RuntimeDefinition{
+			Executables: []string{"python", "python3"},
+			BinDir:      defaultBinDir,
+			EnvVars:     []string{"VIRTUAL_ENV"},
+			VersionProbe: &config.VersionProbe{
+				Args:  []string{"--version"},
+				Regex: `Python (\S+)`,
+			},
+		}
+	default:
+		return config.RuntimeDefinition{
+			Executables: []string{rt},
+			BinDir:      defaultBinDir,
+			EnvVars:     []string{strings.ToUpper(rt) + "_HOME"},
+		}
+	}
+}
+
+// Definition returns the definition of the runtime: the built-in one overlaid
+// with runtime_definitions.<rt> from the config.
+func Definition(cfg *config.Config, rt string) config.RuntimeDefinition {
+	def := builtinDefinition(rt)
+	custom, ok := cfg.RuntimeDefinitions[rt]
+	if !ok {
+		return def
+	}
+	if len(custom.Executables) > 0 {
+		def.Executables = custom.Executables
+	}
+	if custom.BinDir != "" {
+		def.BinDir = custom.BinDir
+	}
+	if len(custom.EnvVars) > 0 {
+		def.EnvVars = custom.EnvVars
+	}
+	if len(custom.Exports) > 0 {
+		def.Exports = custom.Exports
+	}
+	if custom.VersionProbe != nil {
+		def.VersionProbe = custom.VersionProbe
+	}
+	return def
+}
+
+// executables returns the executables of the definition in the form expected by
+// exePath: plain names for the default bin dir, paths relative to the
+// installation directory otherwise.
+func executables(def config.RuntimeDefinition) []string {
+	exes := make([]string, 0, len(def.Executables))
+	for _, e := range def.Executables {
+		if def.BinDir == "" || def.BinDir == defaultBinDir {
+			exes = append(exes, e)
+			continue
+		}
+		exes = append(exes, filepath.Join(def.BinDir, e))
+	}
+	return exes
+}
+
+// exePath returns the path of exe inside the installation directory home.
+// Plain names are looked up in home/bin, names with a path separator are
+// relative to home (e.g. "lib64/R/bin/R").
+func exePath(home, exe string) string {
+	if strings.ContainsRune(exe, filepath.Separator) {
+		return filepath.Join(home, exe)
+	}
+	return filepath.Join(home, "bin", exe)
+}
+
+// hasExecutable reports whether any of exes exists in home.
+func hasExecutable(home string, exes []string) bool {
+	_, ok := findExecutable(home, exes)
+	return ok
+}
+
+func findExecutable(home string, exes []string) (string, bool) {
+	for _, exe := range exes {
+		p := exePath(home, exe)
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// EnvNames returns the variables the runtime installation directory is exported as:
+// env_var of the service runtime, then of the default runtime, then the env_vars
+// of the runtime definition.
+func EnvNames(cfg *config.Config, service, runtime string) []string {
+	if service != "" {
+		if svc, ok := cfg.Services[service]; ok {
+			if rtCfg, ok2 := svc.Runtimes[runtime]; ok2 && rtCfg.EnvVar != "" {
+				return []string{rtCfg.EnvVar}
+			}
+		}
+	}
+	if def, ok := cfg.Default.Runtimes[runtime]; ok && def.EnvVar != "" {
+		return []string{def.EnvVar}
+	}
+	return Definition(cfg, runtime).EnvVars
+}
+
+// Exports returns the "NAME=value" pairs to export for a runtime installed in home:
+// every name of EnvNames followed by the definition exports sorted by name.
+// ${home} in export values is replaced by home, other variables are kept for the shell.
+func Exports(cfg *config.Config, service, runtime, home string) []string {
+	var out []string
+	for _, name := range EnvNames(cfg, service, runtime) {
+		out = append(out, name+"="+home)
+	}
+	def := Definition(cfg, runtime)
+	names := make([]string, 0, len(def.Exports))
+	for name := range def.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := os.Expand(def.Exports[name], func(v string) string {
+			if v == homePlaceholder {
+				return home
+			}
+			return "${" + v + "}"
+		})
+		out = append(out, name+"="+value)
+	}
+	return out
+}
+
+// ProbeVersion runs the version probe of the runtime definition against the
+// installation in home and returns the reported version.
+func ProbeVersion(cfg *config.Config, runtime, home string) (string, error) {
+	def := Definition(cfg, runtime)
+	if def.VersionProbe == nil {
+		return "", errors.New("no version probe defined")
+	}
+	re, err := regexp.Compile(def.VersionProbe.Regex)
+	if err != nil {
+		return "", fmt.Errorf("invalid version probe regex: %w", err)
+	}
+	exe, ok := findExecutable(home, executables(def))
+	if !ok {
+		return "", fmt.Errorf("no executable of runtime '%s' in %s", runtime, home)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionProbeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, exe, def.VersionProbe.Args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("run %s: %w", exe, err)
+	}
+	m := re.FindSubmatch(out)
+	switch {
+	case m == nil:
+		return "", fmt.Errorf("version not found in output of %s", exe)
+	case len(m) > 1:
+		return string(m[1]), nil
+	default:
+		return string(m[0]), nil
+	}
+}
@@ -0,0 +1,119 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestDefinition_BuiltinAndOverlay(t *testing.T) {
+	cfg := &config.Config{RuntimeDefinitions: map[string]config.RuntimeDefinition{
+		"java": {EnvVars: []string{"JDK_HOME"}},
+		"r":    {Executables: []string{"R"}, BinDir: "lib64/R/bin", EnvVars: []string{"R_PREFIX"}},
+	}}
+
+	java := Definition(cfg, "java")
+	if !reflect.DeepEqual(java.Executables, []string{"java"}) || java.EnvVars[0] != "JDK_HOME" {
+		t.Errorf("java definition = %+v", java)
+	}
+	if java.VersionProbe == nil {
+		t.Error("java definition lost the built-in version probe")
+	}
+
+	if got := executables(Definition(cfg, "r")); !reflect.DeepEqual(got, []string{"lib64/R/bin/R"}) {
+		t.Errorf("r executables = %v", got)
+	}
+
+	node := Definition(cfg, "node")
+	if !reflect.DeepEqual(node.Executables, []string{"node"}) ||
+		!reflect.DeepEqual(node.EnvVars, []string{"NODE_HOME"}) {
+		t.Errorf("node fallback definition = %+v", node)
+	}
+}
+
+func TestResolveRuntime_CustomBinDir(t *testing.T) {
+	base := t.TempDir()
+	prefix := filepath.Join(base, "R-4.3.1")
+	mustWriteFile(t, filepath.Join(prefix, "lib64", "R", "bin", "R"), nil)
+	// bin/R alone must not be accepted
+	makeDir(t, base, "R-4.2.0", "R")
+
+	cfg, err := config.Load(writeYAML(t, `
+bigtop_utils:
+  disabled: true
+runtime_definitions:
+  r:
+    executables: [R]
+    bin_dir: lib64/R/bin
+default:
+  runtimes:
+    r:
+      version: "4"
+autodetect:
+  runtimes:
+    r:
+      "4":
+        paths:
+          - "`+base+`/R-*"
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got, err := ResolveRuntime(cfg, "", "r")
+	if err != nil || got != prefix {
+		t.Errorf("ResolveRuntime = (%q, %v), want %q", got, err, prefix)
+	}
+}
+
+func TestExports(t *testing.T) {
+	cfg := &config.Config{RuntimeDefinitions: map[string]config.RuntimeDefinition{
+		"scala": {
+			EnvVars: []string{"SCALA_HOME", "SCALA_ROOT"},
+			Exports: map[string]string{
+				"PATH":      "${home}/bin:${PATH}",
+				"SCALA_LIB": "$home/lib",
+			},
+		},
+	}}
+	got := Exports(cfg, "", "scala", "/opt/scala")
+	want := []string{
+		"SCALA_HOME=/opt/scala",
+		"SCALA_ROOT=/opt/scala",
+		"PATH=/opt/scala/bin:${PATH}",
+		"SCALA_LIB=/opt/scala/lib",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Exports = %v, want %v", got, want)
+	}
+
+	// env_var of the runtime setting replaces the definition env vars
+	cfg.Services = map[string]config.ServiceConfig{
+		"spark": {Runtimes: map[string]config.RuntimeSetting{"scala": {EnvVar: "SPARK_SCALA_HOME"}}},
+	}
+	if got = Exports(cfg, "spark", "scala", "/opt/scala"); got[0] != "SPARK_SCALA_HOME=/opt/scala" || len(got) != 3 {
+		t.Errorf("service Exports = %v", got)
+	}
+}
+
+func TestProbeVersion(t *testing.T) {
+	home := t.TempDir()
+	script := "#!/bin/sh\necho 'openjdk version \"17.0.9\" 2023-10-17' >&2\n"
+	if err := os.MkdirAll(filepath.Join(home, "bin"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "bin", "java"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write java: %v", err)
+	}
+
+	got, err := ProbeVersion(&config.Config{}, "java", home)
+	if err != nil || got != "17.0.9" {
+		t.Errorf("ProbeVersion = (%q, %v), want 17.0.9", got, err)
+	}
+
+	if _, err = ProbeVersion(&config.Config{}, "node", home); err == nil {
+		t.Error("expected error for runtime without version probe")
+	}
+}
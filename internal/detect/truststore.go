@@ -0,0 +1,250 @@
+package detect
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	jksMagic          = 0xFEEDFEED
+	jceksMagic        = 0xCECECECE
+	jksPrivateKeyTag  = 1
+	jksTrustedCertTag = 2
+	jksVersion2       = 2
+)
+
+//nolint:gochecknoglobals // ASN.1 object identifiers of PKCS#7 and PKCS#12
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidCertBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Cert      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+)
+
+// LoadTrustStore returns the certificates of a truststore: a JKS (or JCEKS) keystore,
+// a password-less PKCS#12 keystore, as the cacerts of recent JDKs, or a PEM bundle.
+// Trusted certificate entries and certificate chains of key entries are read; keys are ignored.
+func LoadTrustStore(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	switch {
+	case len(data) >= 4 && (binary.BigEndian.Uint32(data) == jksMagic || binary.BigEndian.Uint32(data) == jceksMagic):
+		certs, err = parseJKS(data)
+	case bytes.Contains(data, []byte("-----BEGIN CERTIFICATE-----")):
+		certs, err = parsePEM(data)
+	default:
+		certs, err = parsePKCS12(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("truststore %s: %w", path, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("truststore %s: no certificates", path)
+	}
+	return certs, nil
+}
+
+func parsePEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// jksReader reads the big-endian fields of a JKS keystore, remembering the first error.
+type jksReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (j *jksReader) uint32() uint32 {
+	var v uint32
+	if j.err == nil {
+		j.err = binary.Read(j.r, binary.BigEndian, &v)
+	}
+	return v
+}
+
+func (j *jksReader) bytes(n int) []byte {
+	if j.err != nil {
+		return nil
+	}
+	// lengths come from the file, a bogus one must not make us allocate gigabytes
+	if n < 0 || n > j.r.Len() {
+		j.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := make([]byte, n)
+	_, j.err = io.ReadFull(j.r, b)
+	return b
+}
+
+func (j *jksReader) utf() string {
+	var n uint16
+	if j.err == nil {
+		j.err = binary.Read(j.r, binary.BigEndian, &n)
+	}
+	return string(j.bytes(int(n)))
+}
+
+// cert reads a certificate: its type for version 2 keystores, length and DER encoding.
+func (j *jksReader) cert(version uint32) []byte {
+	if version == jksVersion2 {
+		if typ := j.utf(); j.err == nil && typ != "X.509" {
+			j.err = fmt.Errorf("unsupported certificate type %s", typ)
+		}
+	}
+	return j.bytes(int(j.uint32()))
+}
+
+func parseJKS(data []byte) ([]*x509.Certificate, error) {
+	const timestampSize = 8
+	j := &jksReader{r: bytes.NewReader(data)}
+	j.uint32() // magic
+	version := j.uint32()
+	count := j.uint32()
+	var ders [][]byte
+	for i := uint32(0); i < count && j.err == nil; i++ {
+		tag := j.uint32()
+		j.utf() // alias
+		j.bytes(timestampSize)
+		switch tag {
+		case jksTrustedCertTag:
+			ders = append(ders, j.cert(version))
+		case jksPrivateKeyTag:
+			j.bytes(int(j.uint32())) // encrypted key
+			for chain := j.uint32(); chain > 0 && j.err == nil; chain-- {
+				ders = append(ders, j.cert(version))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported keystore entry type %d", tag)
+		}
+	}
+	if j.err != nil {
+		return nil, fmt.Errorf("malformed keystore: %w", j.err)
+	}
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  asn1.RawValue `asn1:"optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue `asn1:"tag:0,explicit"`
+	Attributes asn1.RawValue `asn1:"optional"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// parsePKCS12 reads the certificate bags of the unencrypted safe contents of a PKCS#12
+// keystore. Encrypted safe contents, which need the store password, are skipped.
+func parsePKCS12(data []byte) ([]*x509.Certificate, error) {
+	var pfx pfxPDU
+	if err := unmarshalAll(data, &pfx); err != nil {
+		return nil, fmt.Errorf("unknown truststore format: %w", err)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, errors.New("PKCS#12 keystore with public-key integrity is not supported")
+	}
+	var authSafeData []byte
+	if err := unmarshalAll(pfx.AuthSafe.Content.Bytes, &authSafeData); err != nil {
+		return nil, err
+	}
+	var authSafe []contentInfo
+	if err := unmarshalAll(authSafeData, &authSafe); err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	encrypted := false
+	for _, ci := range authSafe {
+		if ci.ContentType.Equal(oidEncryptedData) {
+			encrypted = true
+			continue
+		}
+		if !ci.ContentType.Equal(oidData) {
+			continue
+		}
+		var contents []byte
+		if err := unmarshalAll(ci.Content.Bytes, &contents); err != nil {
+			return nil, err
+		}
+		var bags []safeBag
+		if err := unmarshalAll(contents, &bags); err != nil {
+			return nil, err
+		}
+		for _, bag := range bags {
+			if !bag.ID.Equal(oidCertBag) {
+				continue
+			}
+			var cb certBag
+			if err := unmarshalAll(bag.Value.Bytes, &cb); err != nil {
+				return nil, err
+			}
+			if !cb.ID.Equal(oidX509Cert) {
+				continue
+			}
+			cert, err := x509.ParseCertificate(cb.Data)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	}
+	if len(certs) == 0 && encrypted {
+		return nil, errors.New("certificates of password-protected PKCS#12 keystores are not supported")
+	}
+	return certs, nil
+}
+
+func unmarshalAll(data []byte, v any) error {
+	rest, err := asn1.Unmarshal(data, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing data after ASN.1 structure")
+	}
+	return nil
+}
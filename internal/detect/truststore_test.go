@@ -0,0 +1,139 @@
+package detect
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, cn string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("certificate: %v", err)
+	}
+	return der
+}
+
+// jks encodes a version 2 JKS keystore with a trusted certificate entry and
+// a key entry holding the second certificate as its chain.
+func jks(trusted, chain []byte) []byte {
+	var b bytes.Buffer
+	u32 := func(v uint32) { _ = binary.Write(&b, binary.BigEndian, v) }
+	utf := func(s string) {
+		_ = binary.Write(&b, binary.BigEndian, uint16(len(s)))
+		b.WriteString(s)
+	}
+	u32(jksMagic)
+	u32(jksVersion2)
+	u32(2)
+	u32(jksTrustedCertTag)
+	utf("rootca")
+	b.Write(make([]byte, 8))
+	utf("X.509")
+	u32(uint32(len(trusted)))
+	b.Write(trusted)
+	u32(jksPrivateKeyTag)
+	utf("server")
+	b.Write(make([]byte, 8))
+	u32(3)
+	b.WriteString("key")
+	u32(1)
+	utf("X.509")
+	u32(uint32(len(chain)))
+	b.Write(chain)
+	b.Write(make([]byte, 20)) // digest
+	return b.Bytes()
+}
+
+// pkcs12 encodes a password-less PKCS#12 keystore with the given certificates.
+func pkcs12(t *testing.T, ders ...[]byte) []byte {
+	t.Helper()
+	mustMarshal := func(v any) []byte {
+		out, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return out
+	}
+	explicit := func(inner []byte) asn1.RawValue {
+		return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner}
+	}
+	var bags []safeBag
+	for _, der := range ders {
+		cb := mustMarshal(certBag{ID: oidX509Cert, Data: der})
+		bags = append(bags, safeBag{ID: oidCertBag, Value: explicit(cb)})
+	}
+	contents := mustMarshal(mustMarshal(bags))
+	authSafe := mustMarshal([]contentInfo{{ContentType: oidData, Content: explicit(contents)}})
+	return mustMarshal(pfxPDU{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidData, Content: explicit(mustMarshal(authSafe))},
+	})
+}
+
+func TestLoadTrustStore(t *testing.T) {
+	root, server := selfSigned(t, "root"), selfSigned(t, "server")
+	dir := t.TempDir()
+	pemFile := filepath.Join(dir, "ca-bundle.pem")
+	pemData := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server})...)
+	mustWriteFile(t, pemFile, pemData)
+	jksFile := filepath.Join(dir, "cacerts.jks")
+	mustWriteFile(t, jksFile, jks(root, server))
+	p12File := filepath.Join(dir, "cacerts.p12")
+	mustWriteFile(t, p12File, pkcs12(t, root, server))
+
+	for _, path := range []string{pemFile, jksFile, p12File} {
+		certs, err := LoadTrustStore(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if len(certs) != 2 || certs[0].Subject.CommonName != "root" || certs[1].Subject.CommonName != "server" {
+			t.Errorf("%s: got %d certificates", path, len(certs))
+		}
+	}
+
+	garbage := filepath.Join(dir, "garbage")
+	mustWriteFile(t, garbage, []byte("truststore"))
+	if _, err := LoadTrustStore(garbage); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	truncated := filepath.Join(dir, "truncated.jks")
+	mustWriteFile(t, truncated, jks(root, server)[:40])
+	if _, err := LoadTrustStore(truncated); err == nil {
+		t.Error("expected error for truncated keystore")
+	}
+	// a certificate length past the end of the file is rejected before allocating
+	data := jks(root, server)
+	const certLenOffset = 39
+	binary.BigEndian.PutUint32(data[certLenOffset:], 0xfffffff0)
+	oversized := filepath.Join(dir, "oversized.jks")
+	mustWriteFile(t, oversized, data)
+	if _, err := LoadTrustStore(oversized); err == nil {
+		t.Error("expected error for oversized certificate length")
+	}
+}
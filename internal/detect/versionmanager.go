@@ -0,0 +1,233 @@
+package detect
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// Version manager sources, see Resolution.
+const (
+	SourceSDKMAN = "sdkman"
+	SourceAsdf   = "asdf"
+	SourceJenv   = "jenv"
+	SourcePyenv  = "pyenv"
+)
+
+// legacyJavaMajor is the last Java major version numbered 1.x.
+const legacyJavaMajor = 8
+
+// installVersionRe extracts the version from the name of an installation of a
+// version manager: 17.0.9-tem, temurin-17.0.9+9, openjdk64-1.8.0.392, 3.11.4.
+var installVersionRe = regexp.MustCompile(`(?:^|[-_])v?(\d+(?:\.\d+)*)`)
+
+// managedInstall is a runtime installation of a version manager.
+type managedInstall struct {
+	source string
+	// name is the name of the installation in the version manager, e.g. 17.0.9-tem.
+	name string
+	home string
+	// current is set for the installation selected as current or global.
+	current bool
+}
+
+// version returns the version of the installation, with the legacy 1.x of
+// Java 8 and older given as x, or "" when its name has none.
+func (m managedInstall) version() string {
+	match := installVersionRe.FindStringSubmatch(m.name)
+	if match == nil {
+		return ""
+	}
+	v := match[1]
+	if rest, ok := strings.CutPrefix(v, "1."); ok {
+		major, _, _ := strings.Cut(rest, ".")
+		if n, err := strconv.Atoi(major); err == nil && n <= legacyJavaMajor {
+			return rest
+		}
+	}
+	return v
+}
+
+// matchesVersion reports whether version v of an installation satisfies the
+// version key of autodetect.runtimes: equal, or starting with it at a dot.
+func matchesVersion(v, key string) bool {
+	return v == key || strings.HasPrefix(v, key+".")
+}
+
+// compareVersions compares dotted numeric versions.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x - y
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// managerDirs returns the directories of the version managers of the user with
+// home directory home: SDKMAN, asdf, jenv and pyenv, honouring SDKMAN_DIR,
+// ASDF_DATA_DIR, JENV_ROOT and PYENV_ROOT.
+func managerDirs(home string) map[string]string {
+	dirs := map[string]string{
+		SourceSDKMAN: filepath.Join(home, ".sdkman"),
+		SourceAsdf:   filepath.Join(home, ".asdf"),
+		SourceJenv:   filepath.Join(home, ".jenv"),
+		SourcePyenv:  filepath.Join(home, ".pyenv"),
+	}
+	for source, env := range map[string]string{
+		SourceSDKMAN: "SDKMAN_DIR", SourceAsdf: "ASDF_DATA_DIR", SourceJenv: "JENV_ROOT", SourcePyenv: "PYENV_ROOT",
+	} {
+		if dir := os.Getenv(env); dir != "" {
+			dirs[source] = dir
+		}
+	}
+	return dirs
+}
+
+// asdfPlugin returns the name of the asdf plugin of the runtime.
+func asdfPlugin(rt string) string {
+	switch rt {
+	case "node":
+		return "nodejs"
+	case "go":
+		return "golang"
+	default:
+		return rt
+	}
+}
+
+// managedInstalls returns the installations of the runtime found in the version
+// managers of the user with home directory home, along with the files and
+// directories they were read from.
+func managedInstalls(home, rt string) ([]managedInstall, []string) {
+	dirs := managerDirs(home)
+	var installs []managedInstall
+	var deps []string
+
+	// SDKMAN: candidates/<rt>/<version>, current is a symlink to one of them
+	candidates := filepath.Join(dirs[SourceSDKMAN], "candidates", rt)
+	current := filepath.Base(evalSymlinkOr(filepath.Join(candidates, "current")))
+	installs = append(installs, listInstalls(SourceSDKMAN, candidates, "current", []string{current})...)
+	deps = append(deps, candidates, filepath.Join(candidates, "current"))
+
+	// asdf: installs/<plugin>/<version>, global versions in ~/.tool-versions
+	plugin := asdfPlugin(rt)
+	toolVersions := filepath.Join(home, ".tool-versions")
+	installsDir := filepath.Join(dirs[SourceAsdf], "installs", plugin)
+	installs = append(installs, listInstalls(SourceAsdf, installsDir, "", toolVersionsOf(toolVersions, plugin))...)
+	deps = append(deps, installsDir, toolVersions)
+
+	switch rt {
+	case "java":
+		// jenv: versions/<name> are symlinks to the JDKs, the global one in version
+		versions := filepath.Join(dirs[SourceJenv], "versions")
+		global := filepath.Join(dirs[SourceJenv], "version")
+		installs = append(installs, listInstalls(SourceJenv, versions, "", readVersionFile(global))...)
+		deps = append(deps, versions, global)
+	case "python":
+		// pyenv: versions/<version>, the global ones in version
+		versions := filepath.Join(dirs[SourcePyenv], "versions")
+		global := filepath.Join(dirs[SourcePyenv], "version")
+		installs = append(installs, listInstalls(SourcePyenv, versions, "", readVersionFile(global))...)
+		deps = append(deps, versions, global)
+	}
+	return installs, deps
+}
+
+// listInstalls returns the installations in dir, except skip, the ones named
+// in current marked as current.
+func listInstalls(source, dir, skip string, current []string) []managedInstall {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var installs []managedInstall
+	for _, e := range entries {
+		if e.Name() == skip {
+			continue
+		}
+		home := evalSymlinkOr(filepath.Join(dir, e.Name()))
+		installs = append(installs, managedInstall{
+			source:  source,
+			name:    e.Name(),
+			home:    home,
+			current: slices.Contains(current, e.Name()),
+		})
+	}
+	return installs
+}
+
+// readVersionFile returns the versions listed in a pyenv or jenv version file,
+// one per line.
+func readVersionFile(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var versions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			versions = append(versions, line)
+		}
+	}
+	return versions
+}
+
+// toolVersionsOf returns the versions of the asdf plugin in a .tool-versions file.
+func toolVersionsOf(path, plugin string) []string {
+	for _, line := range readVersionFile(path) {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == plugin {
+			return fields[1:]
+		}
+	}
+	return nil
+}
+
+// detectVersionManagers returns the installation of the runtime version from the
+// version managers of the current user: the current or global one when it
+// matches the version, otherwise the newest matching one.
+func detectVersionManagers(
+	skips *skipLog,
+	cfg *config.Config,
+	runtime, version string,
+	exes []string,
+) (Resolution, bool) {
+	if cfg.VersionManagers.Disabled {
+		return Resolution{}, false
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Resolution{}, false
+	}
+	installs, _ := managedInstalls(home, runtime)
+	var matching []managedInstall
+	for _, m := range installs {
+		if matchesVersion(m.version(), version) && skips.usable(m.home, exes) {
+			matching = append(matching, m)
+		}
+	}
+	if len(matching) == 0 {
+		return Resolution{}, false
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		if matching[i].current != matching[j].current {
+			return matching[i].current
+		}
+		return compareVersions(matching[i].version(), matching[j].version()) > 0
+	})
+	return Resolution{Path: matching[0].home, Source: matching[0].source}, true
+}
@@ -0,0 +1,104 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestManagedInstall_Version(t *testing.T) {
+	tests := map[string]string{
+		"17.0.9-tem":            "17.0.9",
+		"temurin-17.0.9+9":      "17.0.9",
+		"openjdk64-1.8.0.392":   "8.0.392",
+		"adoptopenjdk-8.0.292":  "8.0.292",
+		"3.11.4":                "3.11.4",
+		"miniconda3-latest":     "",
+		"graalvm-community-21":  "21",
+		"zulu-11.68.17":         "11.68.17",
+		"oracle64-1.10.0.2":     "1.10.0.2",
+		"corretto-21.0.1.12.1":  "21.0.1.12.1",
+		"openjdk-22-ea+27-arm7": "22",
+	}
+	for name, want := range tests {
+		if got := (managedInstall{name: name}).version(); got != want {
+			t.Errorf("version of %s = %q, want %q", name, got, want)
+		}
+	}
+	if !matchesVersion("17.0.9", "17") || matchesVersion("170.1", "17") || !matchesVersion("3.9.18", "3.9") {
+		t.Error("matchesVersion")
+	}
+}
+
+func TestResolve_VersionManagers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{"SDKMAN_DIR", "ASDF_DATA_DIR", "JENV_ROOT", "PYENV_ROOT"} {
+		t.Setenv(env, "")
+	}
+
+	// SDKMAN with two JDK 17 and the older one current
+	candidates := filepath.Join(home, ".sdkman", "candidates", "java")
+	tem := makeDir(t, candidates, "17.0.8-tem", "java")
+	makeDir(t, candidates, "17.0.9-zulu", "java")
+	if err := os.Symlink(tem, filepath.Join(candidates, "current")); err != nil {
+		t.Fatal(err)
+	}
+	// jenv with a JDK 8 named 1.8
+	jdk8 := makeDir(t, t.TempDir(), "jdk8", "java")
+	versions := filepath.Join(home, ".jenv", "versions")
+	if err := os.MkdirAll(versions, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(jdk8, filepath.Join(versions, "openjdk64-1.8.0.392")); err != nil {
+		t.Fatal(err)
+	}
+	// asdf and pyenv pythons, the asdf one global
+	asdfPy := makeDir(t, filepath.Join(home, ".asdf", "installs", "python"), "3.9.7", "python")
+	makeDir(t, filepath.Join(home, ".pyenv", "versions"), "3.9.18", "python")
+	toolVersions := []byte("nodejs 20.1.0\npython 3.9.7\n")
+	if err := os.WriteFile(filepath.Join(home, ".tool-versions"), toolVersions, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"trino": {Runtimes: map[string]config.RuntimeSetting{"java": {Version: "17"}}},
+		"spark": {Runtimes: map[string]config.RuntimeSetting{"java": {Version: "8"}, "python": {Version: "3.9"}}},
+	}}
+	cfg.Autodetect.Runtimes = map[string]map[string]config.RuntimeSetting{
+		"java": {"17": {Paths: []string{filepath.Join(home, "nonexistent")}}},
+	}
+
+	tests := []struct {
+		service, runtime, path, source string
+	}{
+		{"trino", "java", tem, SourceSDKMAN},
+		{"spark", "java", jdk8, SourceJenv},
+		{"spark", "python", asdfPy, SourceAsdf},
+	}
+	for _, tt := range tests {
+		res, err := Resolve(cfg, tt.service, tt.runtime)
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.service, tt.runtime, err)
+			continue
+		}
+		if res.Path != tt.path || res.Source != tt.source {
+			t.Errorf("%s %s = %+v, want %s from %s", tt.service, tt.runtime, res, tt.path, tt.source)
+		}
+	}
+
+	// without the current one, the newest matching version wins
+	if err := os.Remove(filepath.Join(candidates, "current")); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := Resolve(cfg, "trino", "java"); res.Path != filepath.Join(candidates, "17.0.9-zulu") {
+		t.Errorf("newest = %+v", res)
+	}
+
+	cfg.VersionManagers.Disabled = true
+	if res, err := Resolve(cfg, "trino", "java"); err == nil {
+		t.Errorf("disabled version managers resolved %+v", res)
+	}
+}
@@ -2,33 +2,85 @@ package exec
 
 import (
 	"fmt"
+	"net"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/output"
 )
 
 const (
-	PortHealthCheckType              = "port"
-	PortHealthCheckTimeoutParamName  = "timeout"
-	PortHealthCheckTimeoutDefault    = 60
-	PortHealthCheckPortParamName     = "port"
-	PortHealthCheckProtocolParamName = "protocol"
-	PortHealthCheckProtocolDefault   = TCP
+	PortHealthCheckType = config.HealthCheckPort
 )
 
 type HealthCheck interface {
 	Check() error
 }
 
-// PortHealthCheck checks that a given port is open by the process with a given PID.
+// Target is the process a health check runs against.
+type Target struct {
+	PID int
+	// Output holds the lines the process wrote since its start, nil when not captured.
+	Output *output.Capture
+	// Offsets are the sizes of the files watched by log checks at the process start.
+	Offsets map[string]int64
+	// StartedAt is when the process was started.
+	StartedAt time.Time
+	// TrustStore is the truststore of the service runtime, used by tls checks.
+	TrustStore string
+}
+
+// NewHealthCheck returns the health check described by cfg for the target process.
+func NewHealthCheck(cfg config.HealthCheckConfig, target Target) (HealthCheck, error) {
+	switch cfg.Type {
+	case PortHealthCheckType:
+		return &PortHealthCheck{PID: target.PID, Config: cfg}, nil
+	case LogHealthCheckType:
+		return &LogHealthCheck{Target: target, Config: cfg}, nil
+	case FileHealthCheckType:
+		return &FileHealthCheck{Target: target, Config: cfg}, nil
+	case TCPConnectHealthCheckType:
+		return &TCPConnectHealthCheck{Config: cfg}, nil
+	case TLSHealthCheckType:
+		return &TLSHealthCheck{Target: target, Config: cfg}, nil
+	case UnixHealthCheckType:
+		return &UnixHealthCheck{PID: target.PID, Config: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown health check type: %s", cfg.Type)
+	}
+}
+
+// decodeParams returns the params of cfg decoded into the struct of its type.
+func decodeParams[T any](cfg config.HealthCheckConfig) (*T, error) {
+	decoded, err := cfg.DecodeParams()
+	if err != nil {
+		return nil, err
+	}
+	params, ok := decoded.(*T)
+	if !ok {
+		return nil, fmt.Errorf("unexpected params %T of health check %s", decoded, cfg.Type)
+	}
+	return params, nil
+}
+
+// PortHealthCheck checks that a given port is open by the process with a given PID:
+// a TCP socket listening on it or an unconnected UDP socket bound to it.
+// With BindAddress, "any" for the wildcard address or an IP, which the wildcard
+// address also satisfies, a socket must be bound to that address; with ForbidLoopback,
+// a socket must be bound to an address other than a loopback one.
 type PortHealthCheck struct {
-	Port     int
-	Timeout  int
-	PID      int
-	Config   config.HealthCheckConfig
-	Protocol SocketProtocol
+	Port           int
+	Timeout        time.Duration
+	Interval       time.Duration
+	PID            int
+	Config         config.HealthCheckConfig
+	Protocol       SocketProtocol
+	BindAddress    string
+	ForbidLoopback bool
+	// Backend is how the sockets are looked up, see GetListeningSocketsForPid.
+	Backend string
 }
 
 func (h *PortHealthCheck) Check() error {
@@ -39,77 +91,60 @@ func (h *PortHealthCheck) Check() error {
 		return err
 	}
 
-	endTime := time.Now().Add(time.Duration(h.Timeout) * time.Second)
-	for time.Now().Before(endTime) {
-		switch h.Protocol {
-		case TCP, TCP6:
-			if infos, err = GetTCPSocketsForPid(h.PID); err != nil {
-				fmt.Fprintf(os.Stderr, "Error getting TCP sockets for PID, retrying: %v\n", err)
-			}
-		case UDP, UDP6:
-			if infos, err = GetUDPSocketsForPid(h.PID); err != nil {
-				fmt.Fprintf(os.Stderr, "Error getting UDP sockets for PID, retrying: %v\n", err)
-			}
-		default:
-			return fmt.Errorf("invalid socket protocol: %s", h.Protocol)
+	// addresses the port is open on, but not the expected ones
+	var bound []string
+	endTime := time.Now().Add(h.Timeout)
+	for {
+		if infos, err = GetListeningSocketsForPid(h.PID, h.Protocol, h.Port, h.Backend); err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting %s sockets for PID, retrying: %v\n", h.Protocol, err)
 		}
+		bound = nil
 		for _, info := range infos {
-			if info.Port == h.Port {
+			if h.boundAsExpected(info.IP) {
 				return nil
 			}
+			bound = append(bound, info.IP.String())
 		}
-		time.Sleep(1 * time.Second)
-	}
-	return fmt.Errorf("port %d not open after %d seconds", h.Port, h.Timeout)
-}
-
-func (h *PortHealthCheck) parseConfig() error {
-	// Port
-	_, found := h.Config.Params[PortHealthCheckPortParamName]
-	if !found {
-		return fmt.Errorf("missing %s parameter", PortHealthCheckPortParamName)
+		if !time.Now().Before(endTime) {
+			break
+		}
+		time.Sleep(h.Interval)
 	}
-	portStr, ok := h.Config.Params[PortHealthCheckPortParamName].(string)
-	if !ok {
-		return fmt.Errorf("parameter %s has invalid value", PortHealthCheckPortParamName)
+	if len(bound) > 0 {
+		return fmt.Errorf("port %d is open on %s only, not on the expected address after %s",
+			h.Port, strings.Join(bound, ", "), h.Timeout)
 	}
-	var port int
-	var err error
-	port, err = strconv.Atoi(portStr)
-	if err != nil {
-		return fmt.Errorf("parameter %s has invalid value: %w", PortHealthCheckPortParamName, err)
+	if namespace, nsErr := NetNamespace(h.PID); nsErr == nil {
+		return fmt.Errorf("port %d not open in network namespace %s after %s", h.Port, namespace, h.Timeout)
 	}
-	h.Port = port
+	return fmt.Errorf("port %d not open after %s", h.Port, h.Timeout)
+}
 
-	// Protocol
-	_, found = h.Config.Params[PortHealthCheckProtocolParamName]
-	if !found {
-		h.Protocol = PortHealthCheckProtocolDefault
-	} else {
-		var protocol string
-		protocol, ok = h.Config.Params[PortHealthCheckProtocolParamName].(string)
-		if !ok {
-			return fmt.Errorf("parameter %s has invalid value", PortHealthCheckProtocolParamName)
-		}
-		h.Protocol = SocketProtocol(protocol)
+func (h *PortHealthCheck) boundAsExpected(ip net.IP) bool {
+	if h.ForbidLoopback && ip.IsLoopback() {
+		return false
+	}
+	switch h.BindAddress {
+	case "":
+		return true
+	case config.BindAny:
+		return ip.IsUnspecified()
+	default:
+		return ip.IsUnspecified() || ip.Equal(net.ParseIP(h.BindAddress))
 	}
+}
 
-	// Timeout
-	_, ok = h.Config.Params[PortHealthCheckTimeoutParamName].(string)
-	if !ok {
-		h.Timeout = PortHealthCheckTimeoutDefault
-	} else {
-		var timeoutStr string
-		timeoutStr, ok = h.Config.Params[PortHealthCheckTimeoutParamName].(string)
-		if !ok {
-			return fmt.Errorf("parameter %s has invalid value", PortHealthCheckTimeoutParamName)
-		}
-		var timeout int
-		timeout, err = strconv.Atoi(timeoutStr)
-		if err != nil {
-			return fmt.Errorf("parameter %s has invalid value: %w", PortHealthCheckTimeoutParamName, err)
-		}
-		h.Timeout = timeout
+func (h *PortHealthCheck) parseConfig() error {
+	params, err := decodeParams[config.PortCheckParams](h.Config)
+	if err != nil {
+		return err
 	}
+	h.Port = int(params.Port)
+	h.Protocol = SocketProtocol(params.Protocol)
+	h.Timeout = params.Timeout.Std()
+	h.Interval = params.Interval.Std()
+	h.BindAddress = params.BindAddress
+	h.ForbidLoopback = bool(params.ForbidLoopback)
+	h.Backend = params.Backend
 	return nil
 }
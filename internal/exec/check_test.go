@@ -0,0 +1,75 @@
+package exec
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func portCheck(params map[string]any) *PortHealthCheck {
+	return &PortHealthCheck{
+		PID:    os.Getpid(),
+		Config: config.HealthCheckConfig{Type: PortHealthCheckType, Params: params},
+	}
+}
+
+func TestPortHealthCheck_OutboundConnectionDoesNotCount(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	listener.Close()
+
+	// only the outbound connection from this port is left
+	localPort := strconv.Itoa(conn.LocalAddr().(*net.TCPAddr).Port)
+	if err = portCheck(map[string]any{"port": localPort, "timeout": "1"}).Check(); err == nil {
+		t.Error("expected error for a port used by an outbound connection")
+	}
+}
+
+func TestPortHealthCheck_BindAddress(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	if err = portCheck(map[string]any{"port": port, "bind_address": "127.0.0.1"}).Check(); err != nil {
+		t.Errorf("bound to the expected address: %v", err)
+	}
+	for _, params := range []map[string]any{
+		{"port": port, "timeout": "1", "bind_address": "any"},
+		{"port": port, "timeout": "1", "forbid_loopback": "true"},
+	} {
+		err = portCheck(params).Check()
+		if err == nil || !strings.Contains(err.Error(), "127.0.0.1 only") {
+			t.Errorf("%v: err = %v", params, err)
+		}
+	}
+	if err = portCheck(map[string]any{"port": port, "bind_address": "localhost"}).Check(); err == nil {
+		t.Error("expected error for invalid bind_address")
+	}
+}
+
+func TestPortHealthCheck_Wildcard(t *testing.T) {
+	listener, err := net.Listen("tcp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	params := map[string]any{"port": port, "bind_address": "10.0.0.5", "forbid_loopback": "true"}
+	if err = portCheck(params).Check(); err != nil {
+		t.Errorf("wildcard address: %v", err)
+	}
+}
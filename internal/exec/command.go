@@ -0,0 +1,41 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const outputWaitDelay = time.Second
+
+// RunCommand runs command (executable and arguments) to completion with the
+// environment a service gets from envVars, see Environ. Its output goes to output.
+// The command runs in its own process group, which is killed once timeout passes.
+func RunCommand(command []string, envVars map[string]string, timeout time.Duration, output io.Writer) error {
+	if len(command) == 0 {
+		return errors.New("empty command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = Environ(envVars)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Do not wait for background children keeping the output open.
+	cmd.WaitDelay = outputWaitDelay
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
@@ -3,19 +3,65 @@ package exec
 import (
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
+	"sort"
+	"syscall"
 )
 
-// RunExecutableAsync starts the given service with the provided arguments in a non-blocking way.
-func RunExecutableAsync(executablePath string, args []string, envVars map[string]string) (*exec.Cmd, error) {
+// serviceWaitDelay bounds the wait for the output of a service once it exited.
+// Output that is not an *os.File is copied from pipes, which a daemonized child
+// of the service may keep open long after the service itself is gone.
+const serviceWaitDelay = outputWaitDelay
+
+func command(executablePath string, args []string, envVars map[string]string, stdout, stderr io.Writer) *exec.Cmd {
 	ctx := context.TODO()
 	cmd := exec.CommandContext(ctx, executablePath, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = serviceWaitDelay
+	cmd.Env = Environ(envVars)
+	return cmd
+}
 
-	// Add environment variables to the command.
+// Environ returns the environment of a service process and its hooks: envVars
+// as KEY=VALUE sorted by name, or nil to inherit the environment of this process
+// when envVars is empty.
+func Environ(envVars map[string]string) []string {
+	var env []string
 	for k, v := range envVars {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
+	sort.Strings(env)
+	return env
+}
+
+// RunExecutableAsync starts the given service with the provided arguments in a non-blocking way.
+// The output of the service goes to stdout and stderr; nil discards it.
+func RunExecutableAsync(
+	executablePath string,
+	args []string,
+	envVars map[string]string,
+	stdout, stderr io.Writer,
+) (*exec.Cmd, error) {
+	cmd := command(executablePath, args, envVars, stdout, stderr)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
 
+// RunExecutableGroupAsync is RunExecutableAsync with the service started in its own
+// process group, whose ID is the PID of the service, so that signals can be sent
+// to the service and all of its children at once.
+func RunExecutableGroupAsync(
+	executablePath string,
+	args []string,
+	envVars map[string]string,
+	stdout, stderr io.Writer,
+) (*exec.Cmd, error) {
+	cmd := command(executablePath, args, envVars, stdout, stderr)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
@@ -23,8 +69,8 @@ func RunExecutableAsync(executablePath string, args []string, envVars map[string
 }
 
 // RunExecutable starts the given service with the provided arguments in a blocking way.
-func RunExecutable(executablePath string, args []string, envVars map[string]string) error {
-	cmd, err := RunExecutableAsync(executablePath, args, envVars)
+func RunExecutable(executablePath string, args []string, envVars map[string]string, stdout, stderr io.Writer) error {
+	cmd, err := RunExecutableAsync(executablePath, args, envVars, stdout, stderr)
 	if err != nil {
 		return err
 	}
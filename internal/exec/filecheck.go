@@ -0,0 +1,79 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const (
+	FileHealthCheckType = config.HealthCheckFile
+	// mtimeSlack covers file timestamps taken from the coarse kernel clock, which
+	// lags behind the clock the process start time is read from by up to a tick.
+	mtimeSlack = 20 * time.Millisecond
+)
+
+// FileHealthCheck passes once a file exists, optionally once it is non-empty and
+// was modified after the process start, e.g. a pid or a ready marker file.
+type FileHealthCheck struct {
+	Path               string
+	NonEmpty           bool
+	ModifiedAfterStart bool
+	Timeout            time.Duration
+	Interval           time.Duration
+	Target             Target
+	Config             config.HealthCheckConfig
+}
+
+func (h *FileHealthCheck) Check() error {
+	if err := h.parseConfig(); err != nil {
+		return err
+	}
+	endTime := time.Now().Add(h.Timeout)
+	for {
+		err := h.check()
+		if err == nil {
+			return nil
+		}
+		if !time.Now().Before(endTime) {
+			return fmt.Errorf("%w after %s", err, h.Timeout)
+		}
+		time.Sleep(h.Interval)
+	}
+}
+
+func (h *FileHealthCheck) check() error {
+	info, err := os.Stat(h.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("file %s does not exist", h.Path)
+	}
+	if err != nil {
+		return err
+	}
+	if h.NonEmpty && info.Size() == 0 {
+		return fmt.Errorf("file %s is empty", h.Path)
+	}
+	if h.ModifiedAfterStart && info.ModTime().Before(h.Target.StartedAt.Add(-mtimeSlack)) {
+		return fmt.Errorf("file %s was not modified since the process start", h.Path)
+	}
+	return nil
+}
+
+func (h *FileHealthCheck) parseConfig() error {
+	params, err := decodeParams[config.FileCheckParams](h.Config)
+	if err != nil {
+		return err
+	}
+	h.Path = params.Path
+	h.NonEmpty = bool(params.NonEmpty)
+	h.ModifiedAfterStart = bool(params.ModifiedAfterStart)
+	if h.ModifiedAfterStart && h.Target.StartedAt.IsZero() {
+		return errors.New("parameter modified_after_start needs the process start time")
+	}
+	h.Timeout = params.Timeout.Std()
+	h.Interval = params.Interval.Std()
+	return nil
+}
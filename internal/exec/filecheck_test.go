@@ -0,0 +1,63 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func fileCheck(target Target, params map[string]any) *FileHealthCheck {
+	return &FileHealthCheck{Target: target, Config: config.HealthCheckConfig{Type: FileHealthCheckType, Params: params}}
+}
+
+func TestFileHealthCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ready")
+	params := map[string]any{"path": file, "timeout": "1"}
+	if err := fileCheck(Target{}, params).Check(); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("missing file: err = %v", err)
+	}
+
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := fileCheck(Target{}, params).Check(); err != nil {
+		t.Errorf("existing file: %v", err)
+	}
+	params["non_empty"] = "true"
+	if err := fileCheck(Target{}, params).Check(); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("empty file: err = %v", err)
+	}
+
+	// a file left over from the previous run does not count
+	old := time.Now().Add(-time.Hour)
+	if err := os.WriteFile(file, []byte("42\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(file, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	params["modified_after_start"] = "true"
+	target := Target{StartedAt: time.Now().Add(-time.Minute)}
+	if err := fileCheck(target, params).Check(); err == nil || !strings.Contains(err.Error(), "not modified") {
+		t.Errorf("stale file: err = %v", err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.WriteFile(file, []byte("43\n"), 0o644)
+	}()
+	if err := fileCheck(target, params).Check(); err != nil {
+		t.Errorf("rewritten file: %v", err)
+	}
+
+	params["non_empty"] = "yes please"
+	if err := fileCheck(target, params).Check(); err == nil {
+		t.Error("expected error for invalid non_empty")
+	}
+	if err := fileCheck(target, map[string]any{}).Check(); err == nil {
+		t.Error("expected error without path")
+	}
+}
@@ -0,0 +1,146 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const (
+	LogHealthCheckType = config.HealthCheckLog
+)
+
+// LogHealthCheck passes once a line written after the process start matches a pattern
+// and fails as soon as a line matches the failure pattern. Lines are read from a file
+// or, without one, from the captured output of the process, optionally of one stream only.
+type LogHealthCheck struct {
+	File           string
+	Stream         string
+	Pattern        *regexp.Regexp
+	FailurePattern *regexp.Regexp
+	Timeout        time.Duration
+	Interval       time.Duration
+	Target         Target
+	Config         config.HealthCheckConfig
+}
+
+func (h *LogHealthCheck) Check() error {
+	if err := h.parseConfig(); err != nil {
+		return err
+	}
+	next := h.reader()
+	endTime := time.Now().Add(h.Timeout)
+	for {
+		lines, err := next()
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if h.FailurePattern != nil && h.FailurePattern.MatchString(line) {
+				return fmt.Errorf("failure pattern %q matched: %s", h.FailurePattern, line)
+			}
+			if h.Pattern.MatchString(line) {
+				return nil
+			}
+		}
+		if !time.Now().Before(endTime) {
+			return fmt.Errorf("pattern %q not found after %s", h.Pattern, h.Timeout)
+		}
+		time.Sleep(h.Interval)
+	}
+}
+
+// reader returns a function returning the lines written since its previous call.
+func (h *LogHealthCheck) reader() func() ([]string, error) {
+	if h.File == "" {
+		from := 0
+		return func() ([]string, error) {
+			captured, next := h.Target.Output.Lines(from)
+			from = next
+			var lines []string
+			for _, l := range captured {
+				if h.Stream == "" || l.Stream == h.Stream {
+					lines = append(lines, l.Text)
+				}
+			}
+			return lines, nil
+		}
+	}
+	t := &fileTail{path: h.File, offset: h.Target.Offsets[h.File]}
+	return t.next
+}
+
+func (h *LogHealthCheck) parseConfig() error {
+	params, err := decodeParams[config.LogCheckParams](h.Config)
+	if err != nil {
+		return err
+	}
+	h.File = params.File
+	h.Stream = params.Stream
+	if h.File == "" && h.Target.Output == nil {
+		return errors.New("missing file parameter and the output is not captured")
+	}
+	// the patterns are validated when the params are decoded
+	h.Pattern = regexp.MustCompile(params.Pattern)
+	h.FailurePattern = nil
+	if params.FailurePattern != "" {
+		h.FailurePattern = regexp.MustCompile(params.FailurePattern)
+	}
+	h.Timeout = params.Timeout.Std()
+	h.Interval = params.Interval.Std()
+	return nil
+}
+
+// fileTail reads the complete lines appended to a file. A file that shrank,
+// e.g. after a rotation, is read again from its beginning.
+type fileTail struct {
+	path    string
+	offset  int64
+	partial []byte
+}
+
+func (t *fileTail) next() ([]string, error) {
+	f, err := os.Open(t.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < t.offset {
+		t.offset = 0
+		t.partial = nil
+	}
+	if _, err = f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	t.offset += int64(len(data))
+	t.partial = append(t.partial, data...)
+
+	var lines []string
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(t.partial[:i], []byte{'\r'})))
+		t.partial = t.partial[i+1:]
+	}
+	return lines, nil
+}
@@ -0,0 +1,74 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/output"
+)
+
+func logCheck(target Target, params map[string]any) *LogHealthCheck {
+	return &LogHealthCheck{Target: target, Config: config.HealthCheckConfig{Type: LogHealthCheckType, Params: params}}
+}
+
+func TestLogHealthCheck_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "server.log")
+	old := "[2026-01-01] started (kafka.server.KafkaServer)\n"
+	if err := os.WriteFile(file, []byte(old), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	target := Target{Offsets: map[string]int64{file: int64(len(old))}}
+	params := map[string]any{
+		"file":    file,
+		"pattern": `started \(kafka.server.KafkaServer\)`,
+		"timeout": "1",
+	}
+
+	// the line from the previous run is ignored
+	if err := logCheck(target, params).Check(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("old line: err = %v, want timeout", err)
+	}
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+		defer f.Close()
+		fmt.Fprint(f, "[2026-01-02] starting\n[2026-01-02] started (kafka.server.")
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(f, "KafkaServer)\n")
+	}()
+	if err := logCheck(target, params).Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
+
+func TestLogHealthCheck_Output(t *testing.T) {
+	capture := output.NewCapture(0)
+	fmt.Fprintln(capture.Writer(output.StreamStdout), "SERVER STARTED")
+	fmt.Fprintln(capture.Writer(output.StreamStderr), "java.net.BindException: Address already in use")
+
+	params := map[string]any{
+		"pattern":         "SERVER STARTED",
+		"failure_pattern": "FATAL|Address already in use",
+		"stream":          "stderr",
+		"timeout":         "1",
+	}
+	err := logCheck(Target{Output: capture}, params).Check()
+	if err == nil || !strings.Contains(err.Error(), "BindException") {
+		t.Errorf("failure pattern: err = %v", err)
+	}
+
+	params["stream"] = "stdout"
+	if err = logCheck(Target{Output: capture}, params).Check(); err != nil {
+		t.Errorf("stdout only: %v", err)
+	}
+
+	if err = logCheck(Target{}, params).Check(); err == nil {
+		t.Error("expected error without file and captured output")
+	}
+}
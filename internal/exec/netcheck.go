@@ -0,0 +1,159 @@
+package exec
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/detect"
+)
+
+const (
+	TCPConnectHealthCheckType = config.HealthCheckTCPConnect
+	TLSHealthCheckType        = config.HealthCheckTLS
+	netHealthCheckDialTimeout = 5 * time.Second
+)
+
+// TCPConnectHealthCheck passes once a TCP connection to host:port succeeds,
+// whichever process accepts it.
+type TCPConnectHealthCheck struct {
+	Address  string
+	Timeout  time.Duration
+	Interval time.Duration
+	Config   config.HealthCheckConfig
+}
+
+func (h *TCPConnectHealthCheck) Check() error {
+	params, err := decodeParams[config.TCPConnectCheckParams](h.Config)
+	if err != nil {
+		return err
+	}
+	h.Address = net.JoinHostPort(params.Host, strconv.Itoa(int(params.Port)))
+	h.Timeout, h.Interval = params.Timeout.Std(), params.Interval.Std()
+	return retryFor(h.Timeout, h.Interval, func() error {
+		conn, dialErr := net.DialTimeout("tcp", h.Address, netHealthCheckDialTimeout)
+		if dialErr != nil {
+			return dialErr
+		}
+		return conn.Close()
+	})
+}
+
+// TLSHealthCheck passes once a TLS handshake with host:port completes. With verify,
+// the certificate chain is verified against the truststore, the one of the service
+// runtime when not given, and the certificate must be valid for server_name, host by default.
+// With min_validity, the certificate must not expire within that duration.
+type TLSHealthCheck struct {
+	Address     string
+	ServerName  string
+	Verify      bool
+	TrustStore  string
+	MinValidity time.Duration
+	Timeout     time.Duration
+	Interval    time.Duration
+	Target      Target
+	Config      config.HealthCheckConfig
+}
+
+func (h *TLSHealthCheck) Check() error {
+	if err := h.parseConfig(); err != nil {
+		return err
+	}
+	var roots *x509.CertPool
+	if h.Verify {
+		var err error
+		if roots, err = h.roots(); err != nil {
+			return err
+		}
+	}
+	return retryFor(h.Timeout, h.Interval, func() error {
+		dialer := &net.Dialer{Timeout: netHealthCheckDialTimeout}
+		// the chain is verified below, so a self-signed certificate can be checked for expiry alone
+		conn, err := tls.DialWithDialer(dialer, "tcp", h.Address, &tls.Config{
+			ServerName:         h.ServerName,
+			InsecureSkipVerify: true, //nolint:gosec // verified against the truststore below
+		})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return h.verify(conn.ConnectionState().PeerCertificates, roots)
+	})
+}
+
+func (h *TLSHealthCheck) verify(certs []*x509.Certificate, roots *x509.CertPool) error {
+	if len(certs) == 0 {
+		return errors.New("no server certificate")
+	}
+	leaf := certs[0]
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: h.ServerName}
+		if _, err := leaf.Verify(opts); err != nil {
+			return err
+		}
+	}
+	if h.MinValidity > 0 && time.Until(leaf.NotAfter) < h.MinValidity {
+		return fmt.Errorf("certificate %s expires at %s, within %s", leaf.Subject, leaf.NotAfter.Format(time.RFC3339),
+			h.MinValidity)
+	}
+	return nil
+}
+
+func (h *TLSHealthCheck) roots() (*x509.CertPool, error) {
+	if h.TrustStore == "" {
+		return x509.SystemCertPool()
+	}
+	certs, err := detect.LoadTrustStore(h.TrustStore)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+func (h *TLSHealthCheck) parseConfig() error {
+	params, err := decodeParams[config.TLSCheckParams](h.Config)
+	if err != nil {
+		return err
+	}
+	h.Address = net.JoinHostPort(params.Host, strconv.Itoa(int(params.Port)))
+	h.Timeout, h.Interval = params.Timeout.Std(), params.Interval.Std()
+	h.Verify = bool(params.Verify)
+	h.ServerName = params.ServerName
+	if h.ServerName == "" {
+		h.ServerName = params.Host
+	}
+	h.TrustStore = params.TrustStore
+	if h.TrustStore == "" {
+		h.TrustStore = h.Target.TrustStore
+	}
+	h.MinValidity = params.MinValidity.Std()
+	return nil
+}
+
+// retryFor calls f every interval until it succeeds or timeout passed, then returns its last error.
+func retryFor(timeout, interval time.Duration, f func() error) error {
+	endTime := time.Now().Add(timeout)
+	for {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if !time.Now().Before(endTime) {
+			return fmt.Errorf("not healthy after %s: %w", timeout, err)
+		}
+		time.Sleep(interval)
+	}
+}
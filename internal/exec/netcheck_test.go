@@ -0,0 +1,152 @@
+package exec
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func netCheck(typ string, target Target, params map[string]any) (HealthCheck, error) {
+	return NewHealthCheck(config.HealthCheckConfig{Type: typ, Params: params}, target)
+}
+
+func listenerPort(l net.Listener) string {
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func TestTCPConnectHealthCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listenerPort(l)
+	check, _ := netCheck(TCPConnectHealthCheckType, Target{}, map[string]any{"port": port, "timeout": "0"})
+	if err = check.Check(); err != nil {
+		t.Errorf("open port: %v", err)
+	}
+	l.Close()
+	if err = check.Check(); err == nil {
+		t.Error("expected error for closed port")
+	}
+	check, _ = netCheck(TCPConnectHealthCheckType, Target{}, map[string]any{"port": "http"})
+	if err = check.Check(); err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
+
+// tlsServer serves TLS on a loopback port with a certificate for localhost and
+// 127.0.0.1 issued by a test CA, whose PEM file is returned.
+func tlsServer(t *testing.T, notAfter time.Time) (string, string) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("ca: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kafka"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("certificate: %v", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	truststore := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	if err = os.WriteFile(truststore, caPEM, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return listenerPort(l), truststore
+}
+
+func TestTLSHealthCheck(t *testing.T) {
+	port, truststore := tlsServer(t, time.Now().Add(12*time.Hour))
+	cases := []struct {
+		name    string
+		target  Target
+		params  map[string]any
+		wantErr string
+	}{
+		{name: "handshake only", params: map[string]any{}},
+		{name: "service truststore", target: Target{TrustStore: truststore}, params: map[string]any{"verify": "true"}},
+		{name: "explicit truststore", params: map[string]any{"verify": "true", "truststore": truststore}},
+		{
+			name:    "system roots",
+			params:  map[string]any{"verify": "true"},
+			wantErr: "unknown authority",
+		},
+		{
+			name:    "hostname mismatch",
+			params:  map[string]any{"verify": "true", "truststore": truststore, "server_name": "kafka-1.example.com"},
+			wantErr: "kafka-1.example.com",
+		},
+		{
+			name:    "expires soon",
+			params:  map[string]any{"verify": "true", "truststore": truststore, "min_validity": "720h"},
+			wantErr: "expires",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.params["port"] = port
+			tc.params["timeout"] = "0"
+			check, _ := netCheck(TLSHealthCheckType, tc.target, tc.params)
+			err := check.Check()
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("Check: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("Check = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// netlinkSockDiag is NETLINK_SOCK_DIAG (formerly NETLINK_INET_DIAG).
+	netlinkSockDiag  = 4
+	sockDiagByFamily = 20
+	// inetDiagReqBytecode is the request attribute holding a socket filter program.
+	inetDiagReqBytecode = 1
+	// inetDiagBCSourceEq is INET_DIAG_BC_S_EQ: the source port equals the one of the next op.
+	inetDiagBCSourceEq = 11
+	inetDiagBCOpSize   = 4
+	netlinkTimeout     = 5 * time.Second
+	netlinkBufferSize  = 32 * 1024
+	tcpStateListen     = 10
+	udpStateUnconnect  = 7
+)
+
+// inetDiagSockID is struct inet_diag_sockid. Ports and addresses are in network byte order.
+type inetDiagSockID struct {
+	SPort  [2]byte
+	DPort  [2]byte
+	Src    [16]byte
+	Dst    [16]byte
+	If     uint32
+	Cookie [2]uint32
+}
+
+// inetDiagReqV2 is struct inet_diag_req_v2.
+type inetDiagReqV2 struct {
+	Family   uint8
+	Protocol uint8
+	Ext      uint8
+	Pad      uint8
+	States   uint32
+	ID       inetDiagSockID
+}
+
+// inetDiagMsg is struct inet_diag_msg.
+type inetDiagMsg struct {
+	Family  uint8
+	State   uint8
+	Timer   uint8
+	Retrans uint8
+	ID      inetDiagSockID
+	Expires uint32
+	RQueue  uint32
+	WQueue  uint32
+	UID     uint32
+	Inode   uint32
+}
+
+// netlinkListeningSockets asks the kernel over NETLINK_SOCK_DIAG for the listening
+// sockets of a protocol, bound to port when it is not 0. Only the network namespace
+// of ad-runtime-utils can be queried.
+func netlinkListeningSockets(protocol SocketProtocol, port int) ([]SocketInfo, error) {
+	var family, proto uint8
+	var states uint32
+	switch protocol {
+	case TCP:
+		family, proto, states = syscall.AF_INET, syscall.IPPROTO_TCP, 1<<tcpStateListen
+	case TCP6:
+		family, proto, states = syscall.AF_INET6, syscall.IPPROTO_TCP, 1<<tcpStateListen
+	case UDP:
+		family, proto, states = syscall.AF_INET, syscall.IPPROTO_UDP, 1<<udpStateUnconnect
+	case UDP6:
+		family, proto, states = syscall.AF_INET6, syscall.IPPROTO_UDP, 1<<udpStateUnconnect
+	default:
+		return nil, fmt.Errorf("invalid socket protocol: %q", protocol)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkSockDiag)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+	tv := syscall.NsecToTimeval(netlinkTimeout.Nanoseconds())
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return nil, err
+	}
+	req := netlinkDiagRequest(inetDiagReqV2{Family: family, Protocol: proto, States: states}, port)
+	if err = syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("netlink request: %w", err)
+	}
+
+	namespace, _ := NetNamespace(os.Getpid())
+	var sockets []SocketInfo
+	buf := make([]byte, netlinkBufferSize)
+	for {
+		n, _, recvErr := syscall.Recvfrom(fd, buf, 0)
+		if recvErr != nil {
+			return nil, fmt.Errorf("netlink response: %w", recvErr)
+		}
+		msgs, parseErr := syscall.ParseNetlinkMessage(buf[:n])
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return sockets, nil
+			case syscall.NLMSG_ERROR:
+				return nil, netlinkError(msg.Data)
+			}
+			var diag inetDiagMsg
+			if err = binary.Read(bytes.NewReader(msg.Data), binary.NativeEndian, &diag); err != nil {
+				return nil, err
+			}
+			sockets = append(sockets, diag.socketInfo(protocol, namespace))
+		}
+	}
+}
+
+// netlinkDiagRequest encodes a dump request, with a filter on the source port when port is not 0.
+func netlinkDiagRequest(req inetDiagReqV2, port int) []byte {
+	var body bytes.Buffer
+	_ = binary.Write(&body, binary.NativeEndian, req)
+	if port != 0 {
+		// yes: jump to the end, i.e. accept; no: jump past the end, i.e. reject
+		const programSize = 2 * inetDiagBCOpSize
+		attr := struct {
+			Len, Type uint16
+			Code, Yes uint8
+			No        uint16
+			_, _      uint8
+			Port      uint16
+		}{
+			Len:  syscall.SizeofRtAttr + programSize,
+			Type: inetDiagReqBytecode,
+			Code: inetDiagBCSourceEq,
+			Yes:  programSize,
+			No:   programSize + inetDiagBCOpSize,
+			Port: uint16(port),
+		}
+		_ = binary.Write(&body, binary.NativeEndian, attr)
+	}
+	hdr := syscall.NlMsghdr{
+		Len:   uint32(syscall.NLMSG_HDRLEN + body.Len()),
+		Type:  sockDiagByFamily,
+		Flags: syscall.NLM_F_REQUEST | syscall.NLM_F_DUMP,
+		Seq:   1,
+	}
+	var msg bytes.Buffer
+	_ = binary.Write(&msg, binary.NativeEndian, hdr)
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
+
+func netlinkError(data []byte) error {
+	const errnoSize = 4
+	if len(data) < errnoSize {
+		return errors.New("netlink: truncated error message")
+	}
+	errno := -int32(binary.NativeEndian.Uint32(data))
+	if errno == 0 {
+		return nil
+	}
+	return fmt.Errorf("netlink: %w", syscall.Errno(errno))
+}
+
+func (m *inetDiagMsg) socketInfo(protocol SocketProtocol, namespace string) SocketInfo {
+	ipLen := net.IPv6len
+	if m.Family == syscall.AF_INET {
+		ipLen = net.IPv4len
+	}
+	return SocketInfo{
+		Protocol:   protocol,
+		IP:         net.IP(append([]byte(nil), m.ID.Src[:ipLen]...)),
+		Port:       int(binary.BigEndian.Uint16(m.ID.SPort[:])),
+		State:      socketState(m.State),
+		RemoteIP:   net.IP(append([]byte(nil), m.ID.Dst[:ipLen]...)),
+		RemotePort: int(binary.BigEndian.Uint16(m.ID.DPort[:])),
+		Namespace:  namespace,
+		Inode:      uint64(m.Inode),
+	}
+}
@@ -0,0 +1,79 @@
+package exec
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestNetlinkListeningSockets(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	// an outbound connection must not be listed
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sockets, err := netlinkListeningSockets(TCP, port)
+	if errors.Is(err, syscall.EPROTONOSUPPORT) || errors.Is(err, syscall.EPERM) {
+		t.Skipf("netlink sock_diag unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sockets) != 1 {
+		t.Fatalf("sockets for port %d = %+v, want the listener only", port, sockets)
+	}
+	soc := sockets[0]
+	if !soc.Listening() || !soc.IP.Equal(net.IPv4(127, 0, 0, 1)) || soc.Inode == 0 {
+		t.Errorf("socket = %+v", soc)
+	}
+
+	// the port filter is applied by the kernel
+	all, err := netlinkListeningSockets(TCP, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) < len(sockets) {
+		t.Errorf("unfiltered dump has %d sockets, filtered %d", len(all), len(sockets))
+	}
+}
+
+func TestGetListeningSocketsForPid_Backends(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	for _, backend := range []string{SocketBackendAuto, SocketBackendProc, SocketBackendNetlink} {
+		sockets, listErr := GetListeningSocketsForPid(os.Getpid(), TCP, port, backend)
+		if backend == SocketBackendNetlink && errors.Is(listErr, syscall.EPROTONOSUPPORT) {
+			continue
+		}
+		if listErr != nil {
+			t.Errorf("%s: %v", backend, listErr)
+			continue
+		}
+		if len(sockets) != 1 || sockets[0].Port != port || !sockets[0].IP.IsUnspecified() {
+			t.Errorf("%s: sockets = %+v", backend, sockets)
+		}
+	}
+	if _, err = GetListeningSocketsForPid(os.Getpid(), TCP, port, "ebpf"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+	params := map[string]any{"port": strconv.Itoa(port), "backend": SocketBackendNetlink, "timeout": "1"}
+	if err = portCheck(params).Check(); err != nil {
+		t.Errorf("port check with netlink: %v", err)
+	}
+}
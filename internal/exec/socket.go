@@ -19,14 +19,108 @@ const (
 	UDP6 SocketProtocol = "udp6"
 )
 
+// Socket states as named by the kernel (include/net/tcp_states.h). UDP sockets
+// are ESTABLISHED when connected and CLOSE otherwise.
+const (
+	SocketStateEstablished = "ESTABLISHED"
+	SocketStateSynSent     = "SYN_SENT"
+	SocketStateSynRecv     = "SYN_RECV"
+	SocketStateFinWait1    = "FIN_WAIT1"
+	SocketStateFinWait2    = "FIN_WAIT2"
+	SocketStateTimeWait    = "TIME_WAIT"
+	SocketStateClose       = "CLOSE"
+	SocketStateCloseWait   = "CLOSE_WAIT"
+	SocketStateLastAck     = "LAST_ACK"
+	SocketStateListen      = "LISTEN"
+	SocketStateClosing     = "CLOSING"
+	SocketStateNewSynRecv  = "NEW_SYN_RECV"
+)
+
 // SocketInfo represents information about a socket.
 // Protocol represents the protocol of the socket (TCP or UDP).
 // IP represents the IP address of the socket.
 // Port represents the port number of the socket.
+// State is the socket state, e.g. LISTEN.
+// RemoteIP and RemotePort are the peer address, unspecified and 0 when not connected.
+// Namespace is the network namespace whose socket table the socket was found in, e.g. "net:[4026531840]".
+// Inode identifies the socket among the file descriptors of processes.
+// Path is the address of a Unix socket.
 type SocketInfo struct {
-	Protocol SocketProtocol
-	IP       net.IP
-	Port     int
+	Protocol   SocketProtocol
+	IP         net.IP
+	Port       int
+	State      string
+	RemoteIP   net.IP
+	RemotePort int
+	Namespace  string
+	Inode      uint64
+	Path       string
+}
+
+// NetNamespace returns the network namespace of the process with the given PID, e.g. "net:[4026531840]".
+func NetNamespace(pid int) (string, error) {
+	return os.Readlink(fmt.Sprintf("/proc/%d/ns/net", pid))
+}
+
+// Listening reports whether the socket accepts connections or datagrams from any peer:
+// a TCP or Unix socket in the LISTEN state or an unconnected UDP socket.
+func (s SocketInfo) Listening() bool {
+	switch s.Protocol {
+	case TCP, TCP6, Unix, UnixGram:
+		return s.State == SocketStateListen
+	default:
+		return s.State == SocketStateClose
+	}
+}
+
+// socketStateName returns the name of a state from its hex code in /proc/net/*.
+func socketStateName(code string) string {
+	n, err := strconv.ParseUint(code, 16, 8)
+	if err != nil {
+		return code
+	}
+	return socketState(uint8(n))
+}
+
+// socketState returns the name of a state from its number.
+func socketState(n uint8) string {
+	names := [...]string{
+		"", SocketStateEstablished, SocketStateSynSent, SocketStateSynRecv, SocketStateFinWait1,
+		SocketStateFinWait2, SocketStateTimeWait, SocketStateClose, SocketStateCloseWait,
+		SocketStateLastAck, SocketStateListen, SocketStateClosing, SocketStateNewSynRecv,
+	}
+	if n == 0 || int(n) >= len(names) {
+		return strconv.Itoa(int(n))
+	}
+	return names[n]
+}
+
+// parseSocketAddress parses an address of /proc/net/*: the IP in hex as stored in
+// memory, i.e. in host (little-endian) order per 32-bit word, and the port in hex.
+func parseSocketAddress(addr string) (net.IP, int, error) {
+	ipHex, portHex, found := strings.Cut(addr, ":")
+	if !found {
+		return nil, 0, fmt.Errorf("invalid socket address %q", addr)
+	}
+	ipBytes, err := hex.DecodeString(ipHex)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(ipBytes) != net.IPv4len && len(ipBytes) != net.IPv6len {
+		return nil, 0, fmt.Errorf("invalid socket address %q", addr)
+	}
+	const word = 4
+	ip := make(net.IP, len(ipBytes))
+	for i := 0; i < len(ipBytes); i += word {
+		for j := range word {
+			ip[i+j] = ipBytes[i+word-1-j]
+		}
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ip, int(port), nil
 }
 
 type NetworkSocketStat struct {
@@ -114,98 +208,106 @@ func parseNetworkStat(statFilePath string) ([]NetworkSocketStat, error) {
 	return stats, scanner.Err()
 }
 
-func getSocketsForInodes(inodes map[string]int, protocol SocketProtocol) ([]SocketInfo, error) {
-	var statFilePath string
+// readSocketTable parses the socket table of the network namespace of the process:
+// /proc/<pid>/net/<protocol>. When it cannot be read, e.g. without the permission to
+// inspect the process, the table of the namespace of ad-runtime-utils is read instead,
+// provided that the process shares it. It returns the namespace the table belongs to.
+func readSocketTable(pid int, protocol SocketProtocol) ([]NetworkSocketStat, string, error) {
 	switch protocol {
-	case TCP:
-		statFilePath = "/proc/net/tcp"
-	case UDP:
-		statFilePath = "/proc/net/udp"
-	case TCP6:
-		statFilePath = "/proc/net/tcp6"
-	case UDP6:
-		statFilePath = "/proc/net/udp6"
+	case TCP, UDP, TCP6, UDP6:
 	default:
-		return nil, fmt.Errorf("invalid socket protocol: %q", protocol)
+		return nil, "", fmt.Errorf("invalid socket protocol: %q", protocol)
 	}
+	if stats, err := parseNetworkStat(fmt.Sprintf("/proc/%d/net/%s", pid, protocol)); err == nil {
+		namespace, _ := NetNamespace(pid)
+		return stats, namespace, nil
+	}
+	// the sockets of another namespace would be someone else's
+	if err := checkSameNetNamespace(pid); err != nil {
+		return nil, "", fmt.Errorf("cannot read the %s sockets of process %d: %w", protocol, pid, err)
+	}
+	stats, err := parseNetworkStat("/proc/net/" + string(protocol))
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, _ := NetNamespace(os.Getpid())
+	return stats, namespace, nil
+}
 
-	netStats, err := parseNetworkStat(statFilePath)
+func getSocketsForInodes(pid int, inodes map[string]int, protocol SocketProtocol) ([]SocketInfo, error) {
+	netStats, namespace, err := readSocketTable(pid, protocol)
 	if err != nil {
 		return nil, err
 	}
 
 	var sockets []SocketInfo
 	for _, stat := range netStats {
-		// Hex IP: addressParts[0], Hex Port AddressPart[1]
-		addressFieldsCount := 2
-		addressParts := strings.Split(stat.LocalAddress, ":")
-		if len(addressParts) != addressFieldsCount {
+		// Check if the socket is in our inodes list
+		if _, exists := inodes[stat.Inode]; !exists {
 			continue
 		}
-
-		var ipBytes []byte
-		ipBytes, err = hex.DecodeString(addressParts[0])
-		if err != nil {
+		inode, inodeErr := strconv.ParseUint(stat.Inode, 10, 64)
+		if inodeErr != nil {
 			continue
 		}
-		// Convert hex IP to the readable format
-		if len(ipBytes) != 4 && len(ipBytes) != 16 {
+		ip, port, addrErr := parseSocketAddress(stat.LocalAddress)
+		if addrErr != nil {
 			continue
 		}
-		ip := net.IP(ipBytes)
-		// Convert hex port to int
-		var port int64
-		port, err = strconv.ParseInt(addressParts[1], 16, 32)
-		if err != nil {
+		remoteIP, remotePort, addrErr := parseSocketAddress(stat.RemoteAddress)
+		if addrErr != nil {
 			continue
 		}
-		// Check if the socket is in our inodes list, inode is fields[9]
-		if _, exists := inodes[stat.Inode]; exists {
-			sockets = append(sockets, SocketInfo{
-				Protocol: protocol,
-				IP:       ip,
-				Port:     int(port),
-			})
-		}
+		sockets = append(sockets, SocketInfo{
+			Protocol:   protocol,
+			IP:         ip,
+			Port:       port,
+			State:      socketStateName(stat.State),
+			RemoteIP:   remoteIP,
+			RemotePort: remotePort,
+			Namespace:  namespace,
+			Inode:      inode,
+		})
 	}
 	return sockets, nil
 }
 
-// GetTCPSocketsForPid returns a list of Listening TCP sockets for the given process ID.
+// GetTCPSocketsForPid returns a list of the TCP sockets, in any state, for the given process ID.
+// Sockets are looked up in the network namespace of the process, see readSocketTable.
 func GetTCPSocketsForPid(pid int) ([]SocketInfo, error) {
 	inodes, err := getInodeForPid(pid)
 	if err != nil {
 		return nil, err
 	}
-	sockets, err := getSocketsForInodes(inodes, TCP)
+	sockets, err := getSocketsForInodes(pid, inodes, TCP)
 	if err != nil {
 		return nil, err
 	}
-	sockets6, err := getSocketsForInodes(inodes, TCP6)
+	sockets6, err := getSocketsForInodes(pid, inodes, TCP6)
 	if err != nil {
 		return nil, err
 	}
 	return append(sockets, sockets6...), nil
 }
 
-// GetUDPSocketsForPid returns a list of Listening UDP sockets for the given process ID.
+// GetUDPSocketsForPid returns a list of the UDP sockets for the given process ID.
 func GetUDPSocketsForPid(pid int) ([]SocketInfo, error) {
 	inodes, err := getInodeForPid(pid)
 	if err != nil {
 		return nil, err
 	}
-	sockets, err := getSocketsForInodes(inodes, UDP)
+	sockets, err := getSocketsForInodes(pid, inodes, UDP)
 	if err != nil {
 		return nil, err
 	}
-	sockets6, err := getSocketsForInodes(inodes, UDP6)
+	sockets6, err := getSocketsForInodes(pid, inodes, UDP6)
 	if err != nil {
 		return nil, err
 	}
 	return append(sockets, sockets6...), nil
 }
 
-// GetSocketsForPid returns a list of all Listening sockets for the given process ID.
+// GetSocketsForPid returns a list of all TCP and UDP sockets for the given process ID.
 func GetSocketsForPid(pid int) ([]SocketInfo, error) {
 	tcpSockets, err := GetTCPSocketsForPid(pid)
 	if err != nil {
@@ -217,3 +319,109 @@ func GetSocketsForPid(pid int) ([]SocketInfo, error) {
 	}
 	return append(tcpSockets, udpSockets...), nil
 }
+
+// Backends listing the sockets of a process.
+const (
+	// SocketBackendAuto uses netlink when the process shares the network namespace
+	// of ad-runtime-utils and the query succeeds, /proc otherwise.
+	SocketBackendAuto = "auto"
+	// SocketBackendNetlink asks the kernel for the listening sockets over NETLINK_SOCK_DIAG.
+	SocketBackendNetlink = "netlink"
+	// SocketBackendProc parses the socket tables in /proc/<pid>/net.
+	SocketBackendProc = "proc"
+)
+
+// GetListeningSocketsForPid returns the listening sockets of the process, see
+// SocketInfo.Listening, for tcp (TCP and TCP6 sockets) or udp (UDP and UDP6 sockets),
+// only those bound to port when it is not 0. backend selects how they are looked up.
+func GetListeningSocketsForPid(pid int, protocol SocketProtocol, port int, backend string) ([]SocketInfo, error) {
+	var protocols []SocketProtocol
+	switch protocol {
+	case TCP, TCP6:
+		protocols = []SocketProtocol{TCP, TCP6}
+	case UDP, UDP6:
+		protocols = []SocketProtocol{UDP, UDP6}
+	default:
+		return nil, fmt.Errorf("invalid socket protocol: %q", protocol)
+	}
+	inodes, err := getInodeForPid(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	var sockets []SocketInfo
+	switch backend {
+	case SocketBackendNetlink:
+		if err = checkSameNetNamespace(pid); err != nil {
+			return nil, fmt.Errorf("netlink: %w", err)
+		}
+		sockets, err = netlinkSockets(protocols, port)
+	case SocketBackendAuto, "":
+		if checkSameNetNamespace(pid) == nil {
+			if sockets, err = netlinkSockets(protocols, port); err == nil {
+				break
+			}
+		}
+		sockets, err = procSockets(pid, inodes, protocols)
+	case SocketBackendProc:
+		sockets, err = procSockets(pid, inodes, protocols)
+	default:
+		return nil, fmt.Errorf("invalid socket backend: %q", backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var owned []SocketInfo
+	for _, soc := range sockets {
+		if _, exists := inodes[strconv.FormatUint(soc.Inode, 10)]; !exists || !soc.Listening() {
+			continue
+		}
+		if port == 0 || soc.Port == port {
+			owned = append(owned, soc)
+		}
+	}
+	return owned, nil
+}
+
+func netlinkSockets(protocols []SocketProtocol, port int) ([]SocketInfo, error) {
+	var sockets []SocketInfo
+	for _, protocol := range protocols {
+		found, err := netlinkListeningSockets(protocol, port)
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, found...)
+	}
+	return sockets, nil
+}
+
+func procSockets(pid int, inodes map[string]int, protocols []SocketProtocol) ([]SocketInfo, error) {
+	var sockets []SocketInfo
+	for _, protocol := range protocols {
+		found, err := getSocketsForInodes(pid, inodes, protocol)
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, found...)
+	}
+	return sockets, nil
+}
+
+// checkSameNetNamespace fails when the process is in another network namespace than
+// ad-runtime-utils, whose sockets netlink and /proc/net do not list, or when the
+// namespace of the process cannot be read.
+func checkSameNetNamespace(pid int) error {
+	own, err := NetNamespace(os.Getpid())
+	if err != nil {
+		return err
+	}
+	target, err := NetNamespace(pid)
+	if err != nil {
+		return err
+	}
+	if own != target {
+		return fmt.Errorf("process %d is in another network namespace %s", pid, target)
+	}
+	return nil
+}
@@ -1,9 +1,12 @@
 package exec
 
 import (
+	"fmt"
 	"net"
 	"os"
+	osexec "os/exec"
 	"testing"
+	"time"
 )
 
 func startSimpleServer(t *testing.T, protocol string) (int, error) {
@@ -41,3 +44,124 @@ func TestGetTCPSocketsForPid(t *testing.T) {
 	}
 	t.Error("Expected to find a socket listening on the port of the started server")
 }
+
+func TestParseSocketAddress(t *testing.T) {
+	cases := []struct {
+		addr string
+		ip   string
+		port int
+	}{
+		{"0100007F:1F90", "127.0.0.1", 8080},
+		{"00000000:2384", "0.0.0.0", 9092},
+		{"0500000A:0016", "10.0.0.5", 22},
+		{"00000000000000000000000001000000:0050", "::1", 80},
+		{"0000000000000000FFFF00000100007F:0050", "127.0.0.1", 80},
+	}
+	for _, tc := range cases {
+		ip, port, err := parseSocketAddress(tc.addr)
+		if err != nil || !ip.Equal(net.ParseIP(tc.ip)) || port != tc.port {
+			t.Errorf("parseSocketAddress(%s) = %v, %d, %v, want %s, %d", tc.addr, ip, port, err, tc.ip, tc.port)
+		}
+	}
+	if _, _, err := parseSocketAddress("0100007F"); err == nil {
+		t.Error("expected error without port")
+	}
+}
+
+func TestGetTCPSocketsForPid_States(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	local := conn.LocalAddr().(*net.TCPAddr)
+
+	sockets, err := GetTCPSocketsForPid(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listening, outbound bool
+	for _, soc := range sockets {
+		switch {
+		case soc.Port == port && soc.Listening():
+			listening = soc.IP.Equal(net.IPv4(127, 0, 0, 1))
+		case soc.Port == local.Port:
+			outbound = soc.State == SocketStateEstablished && soc.RemotePort == port && !soc.Listening()
+		}
+	}
+	if !listening || !outbound {
+		t.Errorf("listening = %v, outbound = %v in %+v", listening, outbound, sockets)
+	}
+}
+
+const netnsHelperEnv = "AD_RUNTIME_UTILS_NETNS_HELPER"
+
+// TestNetnsHelper listens on a TCP port and prints it when run as the helper
+// process of TestGetTCPSocketsForPid_Namespace.
+func TestNetnsHelper(t *testing.T) {
+	if os.Getenv(netnsHelperEnv) == "" {
+		t.Skip("helper process")
+	}
+	listener, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		os.Exit(1)
+	}
+	fmt.Println(listener.Addr().(*net.TCPAddr).Port)
+	time.Sleep(30 * time.Second)
+	os.Exit(0)
+}
+
+func TestGetTCPSocketsForPid_Namespace(t *testing.T) {
+	own, err := NetNamespace(os.Getpid())
+	if err != nil {
+		t.Skipf("no network namespaces: %v", err)
+	}
+	cmd := osexec.Command("unshare", "-rn", os.Args[0], "-test.run=^TestNetnsHelper$")
+	cmd.Env = append(os.Environ(), netnsHelperEnv+"=1")
+	stdout, _ := cmd.StdoutPipe()
+	if err = cmd.Start(); err != nil {
+		t.Skipf("unshare: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	var port int
+	if _, err = fmt.Fscan(stdout, &port); err != nil {
+		t.Skipf("no network namespace for the helper: %v", err)
+	}
+
+	sockets, err := GetTCPSocketsForPid(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, soc := range sockets {
+		if soc.Port == port && soc.Listening() {
+			if soc.Namespace == "" || soc.Namespace == own {
+				t.Errorf("namespace = %q, want the one of the helper, not %q", soc.Namespace, own)
+			}
+			return
+		}
+	}
+	t.Errorf("port %d of the helper not found in %+v", port, sockets)
+}
+
+func TestReadSocketTable_NoFallbackForUnknownNamespace(t *testing.T) {
+	if _, _, err := readSocketTable(os.Getpid(), TCP); err != nil {
+		t.Fatalf("own table: %v", err)
+	}
+	// an exited process: its namespace is unknown, so the own table must not stand in for it
+	cmd := osexec.Command("/bin/true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, _, err := readSocketTable(cmd.Process.Pid, TCP); err == nil {
+		t.Error("expected error for a process whose namespace cannot be read")
+	}
+}
@@ -0,0 +1,142 @@
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Unix is the protocol of Unix domain stream sockets.
+	Unix SocketProtocol = "unix"
+	// UnixGram is the protocol of Unix domain datagram sockets.
+	UnixGram SocketProtocol = "unixgram"
+	// unixAcceptCon is __SO_ACCEPTCON in the flags of /proc/net/unix: the socket listens.
+	unixAcceptCon   = 0x10000
+	unixTypeDgram   = 2
+	unixConnected   = 3
+	unixFieldsCount = 7
+)
+
+// parseUnixSockets parses a /proc/net/unix table. A stream socket accepting
+// connections and a bound, unconnected datagram socket are in the LISTEN state.
+// Path is empty for unbound sockets and starts with "@" for abstract ones.
+func parseUnixSockets(path, namespace string) ([]SocketInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sockets []SocketInfo
+	scanner := bufio.NewScanner(f)
+	// Skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < unixFieldsCount {
+			continue
+		}
+		flags, flagsErr := strconv.ParseUint(fields[3], 16, 32)
+		typ, typErr := strconv.ParseUint(fields[4], 16, 16)
+		st, stErr := strconv.ParseUint(fields[5], 16, 8)
+		inode, inodeErr := strconv.ParseUint(fields[6], 10, 64)
+		if flagsErr != nil || typErr != nil || stErr != nil || inodeErr != nil {
+			continue
+		}
+		soc := SocketInfo{Protocol: Unix, State: SocketStateClose, Namespace: namespace, Inode: inode}
+		if typ == unixTypeDgram {
+			soc.Protocol = UnixGram
+		}
+		if len(fields) > unixFieldsCount {
+			soc.Path = strings.Join(fields[unixFieldsCount:], " ")
+		}
+		switch {
+		case flags&unixAcceptCon != 0:
+			soc.State = SocketStateListen
+		case st == unixConnected:
+			soc.State = SocketStateEstablished
+		case typ == unixTypeDgram && soc.Path != "":
+			soc.State = SocketStateListen
+		}
+		sockets = append(sockets, soc)
+	}
+	return sockets, scanner.Err()
+}
+
+// GetUnixSocketsForPid returns the Unix sockets of the process and of all its
+// descendants, looked up in the network namespace of the process.
+func GetUnixSocketsForPid(pid int) ([]SocketInfo, error) {
+	inodes := make(map[string]int)
+	for _, p := range processTree(pid) {
+		owned, err := getInodeForPid(p)
+		if err != nil {
+			// a descendant may have exited meanwhile
+			if p == pid {
+				return nil, err
+			}
+			continue
+		}
+		for inode, owner := range owned {
+			inodes[inode] = owner
+		}
+	}
+
+	var all []SocketInfo
+	var err error
+	namespace, _ := NetNamespace(pid)
+	if all, err = parseUnixSockets(fmt.Sprintf("/proc/%d/net/unix", pid), namespace); err != nil {
+		// the sockets of another namespace would be someone else's
+		if err = checkSameNetNamespace(pid); err != nil {
+			return nil, fmt.Errorf("cannot read the unix sockets of process %d: %w", pid, err)
+		}
+		namespace, _ = NetNamespace(os.Getpid())
+		if all, err = parseUnixSockets("/proc/net/unix", namespace); err != nil {
+			return nil, err
+		}
+	}
+	var sockets []SocketInfo
+	for _, soc := range all {
+		if _, exists := inodes[strconv.FormatUint(soc.Inode, 10)]; exists {
+			sockets = append(sockets, soc)
+		}
+	}
+	return sockets, nil
+}
+
+// processTree returns pid followed by the PIDs of all its descendants.
+func processTree(pid int) []int {
+	children := make(map[int][]int)
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, stat := range stats {
+		data, err := os.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+		// the command name in parentheses may contain spaces: the parent PID is the
+		// second field after its closing parenthesis
+		end := strings.LastIndexByte(string(data), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[end+1:]))
+		const ppidField = 1
+		if len(fields) <= ppidField {
+			continue
+		}
+		child, childErr := strconv.Atoi(filepath.Base(filepath.Dir(stat)))
+		parent, parentErr := strconv.Atoi(fields[ppidField])
+		if childErr == nil && parentErr == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	tree := []int{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree
+}
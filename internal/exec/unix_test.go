@@ -0,0 +1,136 @@
+package exec
+
+import (
+	"bufio"
+	"net"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const unixHelperEnv = "AD_RUNTIME_UTILS_UNIX_HELPER"
+
+// TestUnixHelper listens on the Unix socket named by its environment when run as
+// the helper process of TestUnixHealthCheck_ProcessTree.
+func TestUnixHelper(t *testing.T) {
+	path := os.Getenv(unixHelperEnv)
+	if path == "" {
+		t.Skip("helper process")
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		os.Exit(1)
+	}
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	os.Stdout.WriteString("ready\n")
+	time.Sleep(30 * time.Second)
+	os.Exit(0)
+}
+
+func TestGetUnixSocketsForPid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	abstract := "@ad-runtime-utils-test-" + strconv.Itoa(os.Getpid())
+	abstractListener, err := net.Listen("unix", abstract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer abstractListener.Close()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sockets, err := GetUnixSocketsForPid(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, soc := range sockets {
+		if soc.Listening() {
+			found[soc.Path] = true
+		} else if soc.Path == path {
+			t.Errorf("connected socket %+v reported as listening", soc)
+		}
+	}
+	if !found[path] || !found[abstract] {
+		t.Errorf("listening sockets %v, want %s and %s", found, path, abstract)
+	}
+}
+
+func TestUnixHealthCheck_ProcessTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sidecar.sock")
+	// the helper is a child of the shell, not the shell itself
+	cmd := osexec.Command("/bin/sh", "-c", `"$0" -test.run='^TestUnixHelper$'; true`, os.Args[0])
+	cmd.Env = append(os.Environ(), unixHelperEnv+"="+path)
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "ready\n" {
+		t.Fatalf("helper: %q, %v", line, err)
+	}
+
+	target := Target{PID: cmd.Process.Pid}
+	check, _ := NewHealthCheck(unixCheckConfig(map[string]any{"path": path, "connect": true}), target)
+	if err := check.Check(); err != nil {
+		t.Errorf("socket of a descendant: %v", err)
+	}
+	check, _ = NewHealthCheck(unixCheckConfig(map[string]any{"path": path + ".2", "timeout": 0}), target)
+	if err := check.Check(); err == nil {
+		t.Error("expected error for a socket that is not bound")
+	}
+	sibling := osexec.Command("/bin/sleep", "30")
+	if err := sibling.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = sibling.Process.Kill()
+		_ = sibling.Wait()
+	}()
+	other := Target{PID: sibling.Process.Pid}
+	check, _ = NewHealthCheck(unixCheckConfig(map[string]any{"path": path, "timeout": 0}), other)
+	if err := check.Check(); err == nil {
+		t.Error("expected error for a socket of another process tree")
+	}
+}
+
+func TestUnixHealthCheck_Datagram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	check, _ := NewHealthCheck(unixCheckConfig(map[string]any{"path": path, "connect": true}), Target{PID: os.Getpid()})
+	if err = check.Check(); err != nil {
+		t.Errorf("connect to a datagram socket: %v", err)
+	}
+}
+
+func unixCheckConfig(params map[string]any) config.HealthCheckConfig {
+	return config.HealthCheckConfig{Type: UnixHealthCheckType, Params: params}
+}
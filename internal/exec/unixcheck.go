@@ -0,0 +1,57 @@
+package exec
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+const (
+	UnixHealthCheckType = config.HealthCheckUnix
+)
+
+// UnixHealthCheck passes once a Unix socket bound to Path listens in the process
+// or one of its descendants. With Connect, a connection to it must succeed as well.
+type UnixHealthCheck struct {
+	Path     string
+	Connect  bool
+	Timeout  time.Duration
+	Interval time.Duration
+	PID      int
+	Config   config.HealthCheckConfig
+}
+
+func (h *UnixHealthCheck) Check() error {
+	params, err := decodeParams[config.UnixCheckParams](h.Config)
+	if err != nil {
+		return err
+	}
+	h.Path = params.Path
+	h.Connect = bool(params.Connect)
+	h.Timeout, h.Interval = params.Timeout.Std(), params.Interval.Std()
+	return retryFor(h.Timeout, h.Interval, h.check)
+}
+
+func (h *UnixHealthCheck) check() error {
+	sockets, err := GetUnixSocketsForPid(h.PID)
+	if err != nil {
+		return err
+	}
+	for _, soc := range sockets {
+		if soc.Path != h.Path || !soc.Listening() {
+			continue
+		}
+		if !h.Connect {
+			return nil
+		}
+		// a datagram socket takes a connect of a datagram socket only
+		conn, dialErr := net.DialTimeout(string(soc.Protocol), h.Path, netHealthCheckDialTimeout)
+		if dialErr != nil {
+			return dialErr
+		}
+		return conn.Close()
+	}
+	return fmt.Errorf("unix socket %s is not bound by process %d or its descendants", h.Path, h.PID)
+}
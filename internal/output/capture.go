@@ -0,0 +1,89 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// DefaultCaptureLines is the number of lines a Capture keeps.
+const DefaultCaptureLines = 10000
+
+// Capture keeps the last lines (between max and twice max) written to its stream writers, so that log health
+// checks can look for patterns in the output of a process.
+type Capture struct {
+	mu    sync.Mutex
+	max   int
+	base  int // index of lines[0] since the creation of the capture
+	lines []CapturedLine
+}
+
+// CapturedLine is a line of output without its newline.
+type CapturedLine struct {
+	Stream string
+	Text   string
+}
+
+// NewCapture returns a Capture keeping the last maxLines lines, DefaultCaptureLines if maxLines is 0.
+func NewCapture(maxLines int) *Capture {
+	if maxLines <= 0 {
+		maxLines = DefaultCaptureLines
+	}
+	return &Capture{max: maxLines}
+}
+
+// Writer returns a writer adding the lines written to it to the capture under stream.
+// Lines longer than MaxLineLength are split like by LineWriter.
+func (c *Capture) Writer(stream string) io.Writer {
+	return &captureWriter{c: c, stream: stream}
+}
+
+// Lines returns the lines captured since index from and the index to use for the
+// next call. Lines dropped because the capture was full are skipped.
+func (c *Capture) Lines(from int) ([]CapturedLine, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if from < c.base {
+		from = c.base
+	}
+	next := c.base + len(c.lines)
+	if from >= next {
+		return nil, next
+	}
+	return append([]CapturedLine(nil), c.lines[from-c.base:]...), next
+}
+
+func (c *Capture) add(stream, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, CapturedLine{Stream: stream, Text: text})
+	// drop old lines in batches to keep adding cheap
+	if len(c.lines) >= 2*c.max {
+		drop := len(c.lines) - c.max
+		c.lines = append([]CapturedLine(nil), c.lines[drop:]...)
+		c.base += drop
+	}
+}
+
+type captureWriter struct {
+	c       *Capture
+	stream  string
+	partial []byte
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.c.add(w.stream, string(bytes.TrimSuffix(w.partial[:i], []byte{'\r'})))
+		w.partial = w.partial[i+1:]
+	}
+	for len(w.partial) >= MaxLineLength {
+		w.c.add(w.stream, string(w.partial[:MaxLineLength]))
+		w.partial = w.partial[MaxLineLength:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,49 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	c := NewCapture(2)
+	out, errw := c.Writer(StreamStdout), c.Writer(StreamStderr)
+	fmt.Fprint(out, "one\r\ntw")
+	fmt.Fprint(errw, "err\n")
+	fmt.Fprint(out, "o\n")
+
+	lines, next := c.Lines(0)
+	want := []CapturedLine{{StreamStdout, "one"}, {StreamStderr, "err"}, {StreamStdout, "two"}}
+	if len(lines) != len(want) || next != 3 {
+		t.Fatalf("Lines(0) = (%v, %d)", lines, next)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %v, want %v", i, lines[i], want[i])
+		}
+	}
+
+	// the oldest lines are dropped once the capture holds twice its size
+	fmt.Fprint(out, "four\nfive\n")
+	if lines, next = c.Lines(next); len(lines) != 2 || lines[1].Text != "five" || next != 5 {
+		t.Errorf("Lines(3) = (%v, %d)", lines, next)
+	}
+	if lines, _ = c.Lines(0); len(lines) != 3 || lines[0].Text != "two" {
+		t.Errorf("Lines(0) after drop = %v", lines)
+	}
+}
+
+func TestCapture_LongLine(t *testing.T) {
+	c := NewCapture(0)
+	w := c.Writer(StreamStdout)
+	long := strings.Repeat("x", MaxLineLength)
+	fmt.Fprint(w, long+"yy")
+	lines, _ := c.Lines(0)
+	if len(lines) != 1 || lines[0].Text != long {
+		t.Fatalf("got %d lines, want one of %d bytes", len(lines), MaxLineLength)
+	}
+	if got := len(w.(*captureWriter).partial); got != 2 {
+		t.Errorf("buffered %d bytes, want the 2 past the limit", got)
+	}
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// Stream names used in line prefixes.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Output holds the writers for the stdout and stderr of a service process.
+type Output struct {
+	Stdout io.Writer
+	Stderr io.Writer
+
+	file  *RotatingFile
+	lines []*LineWriter
+}
+
+// Open returns the output described by cfg. Inherited output goes to stdout and
+// stderr. A nil cfg inherits both streams.
+func Open(cfg *config.OutputConfig, stdout, stderr io.Writer) (*Output, error) {
+	if cfg == nil {
+		return &Output{Stdout: stdout, Stderr: stderr}, nil
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = config.OutputInherit
+	}
+
+	out := &Output{}
+	switch mode {
+	case config.OutputInherit:
+		out.Stdout, out.Stderr = stdout, stderr
+	case config.OutputFile, config.OutputBoth:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("output mode %s requires file", mode)
+		}
+		f, err := OpenRotatingFile(cfg.File, int64(cfg.MaxSize), cfg.MaxAge.Std(), cfg.MaxFiles)
+		if err != nil {
+			return nil, fmt.Errorf("open output file: %w", err)
+		}
+		f.Errors = stderr
+		out.file = f
+		out.Stdout, out.Stderr = f, f
+		if mode == config.OutputBoth {
+			out.Stdout, out.Stderr = io.MultiWriter(stdout, f), io.MultiWriter(stderr, f)
+		}
+	default:
+		return nil, fmt.Errorf("unknown output mode %q", mode)
+	}
+
+	if cfg.Timestamps || cfg.StreamNames {
+		out.Stdout = out.prefixed(out.Stdout, StreamStdout, cfg)
+		out.Stderr = out.prefixed(out.Stderr, StreamStderr, cfg)
+	}
+	return out, nil
+}
+
+func (o *Output) prefixed(w io.Writer, stream string, cfg *config.OutputConfig) io.Writer {
+	lw := &LineWriter{W: w, Timestamps: cfg.Timestamps}
+	if cfg.StreamNames {
+		lw.Stream = stream
+	}
+	o.lines = append(o.lines, lw)
+	return lw
+}
+
+// Close flushes incomplete lines and closes the output file. Inherited streams stay open.
+func (o *Output) Close() error {
+	var errs []error
+	for _, lw := range o.lines {
+		errs = append(errs, lw.Flush())
+	}
+	if o.file != nil {
+		errs = append(errs, o.file.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Synchronized returns a writer serialising the writes to w, so that it can be
+// shared between processes. Files are returned unchanged, so that they are still
+// passed to processes directly.
+func Synchronized(w io.Writer) io.Writer {
+	if _, ok := w.(*os.File); ok {
+		return w
+	}
+	return &syncWriter{w: w}
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
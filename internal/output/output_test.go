@@ -0,0 +1,44 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestOpen(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	out, err := Open(nil, &stdout, &stderr)
+	if err != nil || out.Stdout != &stdout || out.Stderr != &stderr {
+		t.Fatalf("nil config: (%+v, %v), want inherited streams", out, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "svc.log")
+	out, err = Open(&config.OutputConfig{Mode: config.OutputBoth, File: file, StreamNames: true}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	fmt.Fprintln(out.Stdout, "hello")
+	fmt.Fprint(out.Stderr, "oops")
+	if err = out.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if stdout.String() != "[stdout] hello\n" || stderr.String() != "[stderr] oops\n" {
+		t.Errorf("inherited: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+	if got := readFile(t, file); got != "[stdout] hello\n[stderr] oops\n" {
+		t.Errorf("file = %q", got)
+	}
+
+	if _, err = Open(&config.OutputConfig{Mode: config.OutputFile}, &stdout, &stderr); err == nil {
+		t.Error("expected error for file mode without file")
+	}
+	if _, err = Open(&config.OutputConfig{Mode: "syslog"}, &stdout, &stderr); err == nil ||
+		!strings.Contains(err.Error(), "unknown output mode") {
+		t.Errorf("unknown mode: err = %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+	// MaxLineLength is the longest line LineWriter buffers, longer ones are
+	// split into lines of this length.
+	MaxLineLength = 64 << 10
+)
+
+// LineWriter prefixes every line written to it with a timestamp and/or a stream
+// name before passing it to W. Incomplete lines are buffered until their newline
+// or Flush, or until they reach MaxLineLength.
+type LineWriter struct {
+	W          io.Writer
+	Stream     string
+	Timestamps bool
+	// Now returns the time of the timestamps, time.Now when nil.
+	Now func() time.Time
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (l *LineWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := l.writeLine(l.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[i+1:]
+	}
+	for len(l.buf) >= MaxLineLength {
+		if err := l.writeLine(append(l.buf[:MaxLineLength:MaxLineLength], '\n')); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[MaxLineLength:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the buffered incomplete line, terminated with a newline.
+func (l *LineWriter) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) == 0 {
+		return nil
+	}
+	line := append(l.buf, '\n')
+	l.buf = nil
+	return l.writeLine(line)
+}
+
+func (l *LineWriter) writeLine(line []byte) error {
+	var out []byte
+	if l.Timestamps {
+		now := time.Now
+		if l.Now != nil {
+			now = l.Now
+		}
+		out = now().AppendFormat(out, timestampLayout)
+		out = append(out, ' ')
+	}
+	if l.Stream != "" {
+		out = append(out, '[')
+		out = append(out, l.Stream...)
+		out = append(out, "] "...)
+	}
+	out = append(out, line...)
+	_, err := l.W.Write(out)
+	return err
+}
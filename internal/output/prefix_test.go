@@ -0,0 +1,48 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+	at := time.Date(2026, 1, 2, 3, 4, 5, 6_000_000, time.UTC)
+	lw := &LineWriter{W: &buf, Stream: "stderr", Timestamps: true, Now: func() time.Time { return at }}
+
+	for _, chunk := range []string{"first li", "ne\nsecond\nthi", "rd"} {
+		if _, err := lw.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := lw.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	want := "2026-01-02T03:04:05.006Z [stderr] first line\n" +
+		"2026-01-02T03:04:05.006Z [stderr] second\n" +
+		"2026-01-02T03:04:05.006Z [stderr] third\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestLineWriter_LongLine(t *testing.T) {
+	var buf bytes.Buffer
+	lw := &LineWriter{W: &buf, Stream: "stdout"}
+	long := strings.Repeat("x", MaxLineLength)
+	for range 2 {
+		if _, err := lw.Write([]byte(long[:MaxLineLength/2+1])); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	// the line without a newline is written once it reaches the limit
+	want := "[stdout] " + long + "\n"
+	if buf.String() != want {
+		t.Errorf("got %d bytes, want one line of %d", buf.Len(), len(want))
+	}
+	if len(lw.buf) != 2 {
+		t.Errorf("buffered %d bytes, want the 2 past the limit", len(lw.buf))
+	}
+}
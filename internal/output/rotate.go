@@ -0,0 +1,164 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxFiles is the number of rotated files kept when max_files is not set.
+	DefaultMaxFiles = 5
+	fileMode        = 0o644
+	dirMode         = 0o755
+)
+
+// RotatingFile is an append-only log file rotated once it grows over MaxSize bytes
+// or gets older than MaxAge. Rotated files are renamed to <path>.1, <path>.2, ...
+// with .1 the newest one; only MaxFiles of them are kept. It is safe for concurrent use.
+//
+// Writes never fail until Close, so that the copying of a service pipe goes on
+// and the service does not block on a full pipe: when the file cannot be written,
+// rotated or reopened, e.g. on a full disk, the data is dropped and the error is
+// reported to Errors, once until a write succeeds again.
+type RotatingFile struct {
+	Path     string
+	MaxSize  int64
+	MaxAge   time.Duration
+	MaxFiles int
+	Errors   io.Writer
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	opened  time.Time
+	closed  bool
+	failing bool
+}
+
+// OpenRotatingFile opens path for appending, creating it and its directory if needed.
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration, maxFiles int) (*RotatingFile, error) {
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	r := &RotatingFile{Path: path, MaxSize: maxSize, MaxAge: maxAge, MaxFiles: maxFiles}
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	// an existing file is as old as its last rotation, approximated by its modification time
+	r.opened = time.Now()
+	if r.size > 0 {
+		r.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write appends p to the file, rotating it first when p would exceed MaxSize
+// or the file is older than MaxAge. It fails only once the file is closed.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	err := r.write(p)
+	if err != nil && !r.failing && r.Errors != nil {
+		fmt.Fprintf(r.Errors, "warning: log file %s: %v, output is dropped\n", r.Path, err)
+	}
+	r.failing = err != nil
+	return len(p), nil
+}
+
+func (r *RotatingFile) write(p []byte) error {
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	var rotateErr error
+	if r.size > 0 && r.needsRotation(int64(len(p))) {
+		if rotateErr = r.rotate(); rotateErr != nil {
+			rotateErr = fmt.Errorf("rotate: %w", rotateErr)
+			if r.file == nil {
+				return rotateErr
+			}
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+func (r *RotatingFile) needsRotation(n int64) bool {
+	if r.MaxSize > 0 && r.size+n > r.MaxSize {
+		return true
+	}
+	return r.MaxAge > 0 && time.Since(r.opened) >= r.MaxAge
+}
+
+// rotate renames the files and opens a new one. Whatever fails, the file at Path
+// is reopened, so that writing goes on in the current file when renaming failed.
+func (r *RotatingFile) rotate() error {
+	closeErr := r.file.Close()
+	r.file = nil
+	err := errors.Join(closeErr, r.shift())
+	if openErr := r.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	if err == nil {
+		r.opened = time.Now()
+	}
+	return err
+}
+
+// shift renames <path>.N to <path>.N+1, dropping the oldest one, and the file to <path>.1.
+func (r *RotatingFile) shift() error {
+	if err := os.Remove(r.rotatedName(r.MaxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := r.MaxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(r.rotatedName(i), r.rotatedName(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(r.Path, r.rotatedName(1))
+}
+
+func (r *RotatingFile) rotatedName(i int) string {
+	return fmt.Sprintf("%s.%d", r.Path, i)
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,107 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile_Size(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "kafka.out")
+	r, err := OpenRotatingFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile: %v", err)
+	}
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if _, err = r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := readFile(t, path); got != "four\nfive\n" {
+		t.Errorf("current = %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "three\n" {
+		t.Errorf(".1 = %q", got)
+	}
+	if got := readFile(t, path+".2"); got != "one\ntwo\n" {
+		t.Errorf(".2 = %q", got)
+	}
+	if _, err = os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than max_files rotated files kept: %v", err)
+	}
+}
+
+func TestRotatingFile_AgeAndAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kafka.out")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	r, err := OpenRotatingFile(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile: %v", err)
+	}
+	defer r.Close()
+	if _, err = r.Write([]byte("new\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err = r.Write([]byte("newer\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := readFile(t, path+".1"); got != "old\n" {
+		t.Errorf(".1 = %q, want the file older than max_age", got)
+	}
+	if got := readFile(t, path); !strings.HasPrefix(got, "new\nnewer\n") {
+		t.Errorf("current = %q", got)
+	}
+}
+
+func TestRotatingFile_RotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kafka.out")
+	// a non-empty directory in place of the oldest rotated file cannot be removed, even by root
+	if err := os.MkdirAll(filepath.Join(path+".1", "keep"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	r, err := OpenRotatingFile(path, 4, 0, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile: %v", err)
+	}
+	var errs strings.Builder
+	r.Errors = &errs
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		if n, writeErr := r.Write([]byte(line)); n != len(line) || writeErr != nil {
+			t.Fatalf("Write(%q) = %d, %v, want the pipe copying to go on", line, n, writeErr)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := readFile(t, path); got != "one\ntwo\nthree\n" {
+		t.Errorf("current = %q, want writing to go on in the current file", got)
+	}
+	if got := strings.Count(errs.String(), "warning:"); got != 1 {
+		t.Errorf("reported %d times, want once:\n%s", got, errs.String())
+	}
+	if _, err = r.Write([]byte("four\n")); err == nil {
+		t.Error("expected error after Close")
+	}
+}
@@ -0,0 +1,278 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// Group is a set of services supervised together. Services are started in the
+// order given by their after and requires lists and stopped in reverse order.
+// When a service stops for good, the services requiring it are stopped too.
+// Running as PID 1, the group also reaps orphaned processes.
+type Group struct {
+	services   []*Service
+	requiredBy map[string][]*Service
+	reaper     *reaper
+}
+
+// NewGroup orders services by their dependencies. Entries of after and requires
+// naming services outside the group are ignored.
+func NewGroup(services ...*Service) (*Group, error) {
+	return newGroup(os.Getpid() == 1, services...)
+}
+
+func newGroup(reap bool, services ...*Service) (*Group, error) {
+	byName := make(map[string]*Service, len(services))
+	for _, svc := range services {
+		if _, dup := byName[svc.Name]; dup {
+			return nil, fmt.Errorf("service %s is listed twice", svc.Name)
+		}
+		if err := validateRestart(svc.Config.Restart); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		if err := ValidateHooks(svc.Config); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		byName[svc.Name] = svc
+	}
+
+	g := &Group{requiredBy: make(map[string][]*Service)}
+	deps := make(map[string][]string, len(services))
+	for _, svc := range services {
+		for _, req := range svc.Config.Requires {
+			if _, ok := byName[req]; ok {
+				g.requiredBy[req] = append(g.requiredBy[req], svc)
+				deps[svc.Name] = append(deps[svc.Name], req)
+			}
+		}
+		for _, after := range svc.Config.After {
+			if _, ok := byName[after]; ok {
+				deps[svc.Name] = append(deps[svc.Name], after)
+			}
+		}
+	}
+
+	// Depth-first topological sort keeping the given order among independent services.
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(services))
+	var visit func(svc *Service, path []string) error
+	visit = func(svc *Service, path []string) error {
+		switch marks[svc.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), svc.Name)
+		}
+		marks[svc.Name] = visiting
+		for _, dep := range deps[svc.Name] {
+			if err := visit(byName[dep], append(path, svc.Name)); err != nil {
+				return err
+			}
+		}
+		marks[svc.Name] = visited
+		g.services = append(g.services, svc)
+		return nil
+	}
+	for _, svc := range services {
+		if err := visit(svc, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if reap {
+		g.reaper = &reaper{owned: g.owns}
+		for _, svc := range g.services {
+			svc.reaper = g.reaper
+		}
+	}
+	return g, nil
+}
+
+// owns reports whether pid is the process of a service of the group.
+func (g *Group) owns(pid int) bool {
+	for _, svc := range g.services {
+		if svc.pid() == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRestart(policy *config.RestartPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	switch policy.Policy {
+	case config.RestartNo, config.RestartOnFailure, config.RestartAlways:
+		return nil
+	default:
+		return fmt.Errorf("unknown restart policy %q", policy.Policy)
+	}
+}
+
+// Services returns the services of the group in start order.
+func (g *Group) Services() []*Service {
+	return g.services
+}
+
+// Start starts the services one after another, each once the previous one passed
+// its health checks. If a service fails to start, the already started ones are stopped.
+func (g *Group) Start() error {
+	for i, svc := range g.services {
+		if err := svc.Start(); err != nil {
+			if svc.pid() != 0 {
+				_ = svc.Wait()
+			}
+			for j := i - 1; j >= 0; j-- {
+				_ = g.services[j].Stop()
+				_ = g.services[j].Wait()
+			}
+			return fmt.Errorf("start %s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+// HoldReaper keeps the reaper of a group running as PID 1 from collecting the
+// processes started by this process until release is called. It is needed around
+// commands run while the group runs, such as version probes during a reload,
+// whose exit status their own Wait needs.
+func (g *Group) HoldReaper() (release func()) {
+	return g.reaper.hold()
+}
+
+// Run supervises the started services until all of them stopped. When ctx is
+// cancelled, the services are stopped in reverse start order. The returned error
+// joins the errors of the services that stopped on their own.
+func (g *Group) Run(ctx context.Context) error {
+	if g.reaper != nil {
+		reapCtx, stopReaper := context.WithCancel(context.Background())
+		defer stopReaper()
+		go g.reaper.run(reapCtx)
+	}
+
+	type run struct {
+		cancel context.CancelFunc
+		done   chan struct{}
+		err    error
+	}
+	runs := make(map[string]*run, len(g.services))
+	finished := make(chan *Service, len(g.services))
+	for _, svc := range g.services {
+		svcCtx, cancel := context.WithCancel(context.Background())
+		r := &run{cancel: cancel, done: make(chan struct{})}
+		runs[svc.Name] = r
+		go func() {
+			r.err = svc.Supervise(svcCtx)
+			close(r.done)
+			finished <- svc
+		}()
+	}
+
+	stop := func(svc *Service) {
+		runs[svc.Name].cancel()
+		_ = svc.Stop()
+	}
+	var stopDependents func(svc *Service)
+	stopDependents = func(svc *Service) {
+		for _, dep := range g.requiredBy[svc.Name] {
+			stop(dep)
+			stopDependents(dep)
+		}
+	}
+
+	for remaining := len(g.services); remaining > 0; {
+		select {
+		case <-ctx.Done():
+			for i := len(g.services) - 1; i >= 0; i-- {
+				svc := g.services[i]
+				stop(svc)
+				<-runs[svc.Name].done
+			}
+			remaining = 0
+		case svc := <-finished:
+			remaining--
+			stopDependents(svc)
+		}
+	}
+
+	var errs []error
+	for _, svc := range g.services {
+		if err := runs[svc.Name].err; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", svc.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Signal sends sig to the process groups of all running services.
+func (g *Group) Signal(sig syscall.Signal) {
+	for _, svc := range g.services {
+		_ = svc.Signal(sig)
+	}
+}
+
+// HandleSignals returns a context cancelled on SIGINT or SIGTERM, which makes Run
+// stop the services, and forwards SIGQUIT, SIGUSR1 and SIGUSR2 to the services
+// until the returned cancel function is called. SIGHUP calls reload, or is
+// forwarded as well when reload is nil.
+func (g *Group) HandleSignals(parent context.Context, reload func()) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs,
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-sigs:
+				switch sig {
+				case syscall.SIGINT, syscall.SIGTERM:
+					cancel()
+				case syscall.SIGHUP:
+					if reload != nil {
+						reload()
+						break
+					}
+					g.Signal(syscall.SIGHUP)
+				default:
+					if s, ok := sig.(syscall.Signal); ok {
+						g.Signal(s)
+					}
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			cancel()
+		})
+	}
+}
+
+// ExitCode returns the exit code of the first service, in start order, whose
+// last process exited with a non-zero code, 0 if there is none.
+func (g *Group) ExitCode() int {
+	for _, svc := range g.services {
+		if code := svc.Status().LastExitCode; code != nil && *code != 0 {
+			return *code
+		}
+	}
+	return 0
+}
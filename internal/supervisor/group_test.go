@@ -0,0 +1,124 @@
+package supervisor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func shellService(name, script string, mod func(*config.ServiceConfig)) *Service {
+	cfg := config.ServiceConfig{Executable: "/bin/sh", ExecutableArgs: []string{"-c", script}}
+	if mod != nil {
+		mod(&cfg)
+	}
+	return New(name, cfg, nil)
+}
+
+func names(services []*Service) string {
+	out := make([]string, 0, len(services))
+	for _, svc := range services {
+		out = append(out, svc.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestNewGroup_Order(t *testing.T) {
+	sidecar := shellService("sidecar", "true", func(c *config.ServiceConfig) { c.After = []string{"trino", "absent"} })
+	trino := shellService("trino", "true", func(c *config.ServiceConfig) { c.Requires = []string{"metastore"} })
+	metastore := shellService("metastore", "true", nil)
+
+	g, err := NewGroup(sidecar, trino, metastore)
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	if got := names(g.Services()); got != "metastore,trino,sidecar" {
+		t.Errorf("order = %s", got)
+	}
+
+	a := shellService("a", "true", func(c *config.ServiceConfig) { c.After = []string{"b"} })
+	b := shellService("b", "true", func(c *config.ServiceConfig) { c.Requires = []string{"a"} })
+	if _, err = NewGroup(a, b); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("cycle: err = %v", err)
+	}
+
+	bad := shellService("bad", "true", func(c *config.ServiceConfig) {
+		c.Restart = &config.RestartPolicy{Policy: "sometimes"}
+	})
+	if _, err = NewGroup(bad); err == nil {
+		t.Error("expected error for unknown restart policy")
+	}
+}
+
+func TestGroup_RestartOnFailure(t *testing.T) {
+	svc := shellService("flaky", "exit 3", func(c *config.ServiceConfig) {
+		c.Restart = &config.RestartPolicy{Policy: config.RestartOnFailure, MaxRestarts: 2}
+	})
+	g, err := NewGroup(svc)
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	if err = g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err = g.Run(context.Background()); err == nil {
+		t.Fatal("expected error after the last restart")
+	}
+	st := svc.Status()
+	if st.Restarts != 2 || st.LastExitCode == nil || *st.LastExitCode != 3 {
+		t.Errorf("status = %+v, want 2 restarts and exit code 3", st)
+	}
+}
+
+func TestGroup_RequiredServiceExitStopsDependents(t *testing.T) {
+	db := shellService("db", "sleep 0.2", nil)
+	app := shellService("app", "exec sleep 30", func(c *config.ServiceConfig) {
+		c.Requires = []string{"db"}
+		c.Restart = &config.RestartPolicy{Policy: config.RestartAlways}
+	})
+	g, err := NewGroup(app, db)
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	if err = g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- g.Run(context.Background()) }()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("dependent service was not stopped")
+	}
+	if st := app.Status(); st.State != StateExited || st.Restarts != 0 {
+		t.Errorf("app status = %+v", st)
+	}
+}
+
+func TestGroup_StopOnCancel(t *testing.T) {
+	first := shellService("first", "exec sleep 30", nil)
+	second := shellService("second", "exec sleep 30", func(c *config.ServiceConfig) { c.After = []string{"first"} })
+	g, err := NewGroup(first, second)
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	if err = g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = g.Run(ctx); err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	for _, svc := range g.Services() {
+		if st := svc.Status(); st.State != StateExited {
+			t.Errorf("%s state = %s", svc.Name, st.State)
+		}
+	}
+}
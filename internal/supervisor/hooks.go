@@ -0,0 +1,80 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/exec"
+)
+
+// Hook stages.
+const (
+	StagePreStart  = "pre_start"
+	StagePostStart = "post_start"
+	StagePostStop  = "post_stop"
+)
+
+// DefaultHookTimeout is used for hooks without a timeout.
+const DefaultHookTimeout = time.Minute
+
+// RunHooks runs the hooks of a stage one after another with env added to the
+// environment. Hooks whose creates path exists are skipped. The failure of a hook
+// with on_failure "warn" is reported to log and the next hook runs; the failure
+// of any other hook is returned.
+func RunHooks(stage string, hooks []config.HookConfig, env map[string]string, log io.Writer) error {
+	for _, hook := range hooks {
+		if hook.Creates != "" {
+			if _, err := os.Stat(hook.Creates); err == nil {
+				continue
+			}
+		}
+		timeout := hook.Timeout.Std()
+		if timeout <= 0 {
+			timeout = DefaultHookTimeout
+		}
+		err := exec.RunCommand(hook.Command, env, timeout, log)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s hook %q: %w", stage, strings.Join(hook.Command, " "), err)
+		if hook.OnFailure == config.HookOnFailureWarn {
+			fmt.Fprintf(log, "warning: %v\n", err)
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+// runHooks runs the hooks of a stage of the service with the reaper held, so
+// that it does not collect them before RunHooks does.
+func (s *Service) runHooks(stage string, hooks []config.HookConfig, env map[string]string) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	release := s.reaper.hold()
+	defer release()
+	return RunHooks(stage, hooks, env, s.log())
+}
+
+// ValidateHooks checks the hooks of a service: each has a command and a known on_failure.
+func ValidateHooks(cfg config.ServiceConfig) error {
+	for _, hooks := range [][]config.HookConfig{cfg.PreStart, cfg.PostStart, cfg.PostStop} {
+		for _, hook := range hooks {
+			if len(hook.Command) == 0 {
+				return errors.New("hook without command")
+			}
+			switch hook.OnFailure {
+			case "", config.HookOnFailureAbort, config.HookOnFailureWarn:
+			default:
+				return fmt.Errorf("unknown hook on_failure %q", hook.OnFailure)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package supervisor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func sh(script string) []string {
+	return []string{"/bin/sh", "-c", script}
+}
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+	formatted := filepath.Join(dir, "meta.properties")
+	var log bytes.Buffer
+	hooks := []config.HookConfig{
+		{Command: sh(`echo "$JAVA_HOME" > ` + formatted), Creates: formatted},
+		{Command: sh("exit 1"), OnFailure: config.HookOnFailureWarn},
+		{Command: sh("echo done")},
+	}
+	env := map[string]string{"JAVA_HOME": "/opt/jdk"}
+
+	if err := RunHooks(StagePreStart, hooks, env, &log); err != nil {
+		t.Fatalf("RunHooks: %v", err)
+	}
+	if got, _ := os.ReadFile(formatted); string(got) != "/opt/jdk\n" {
+		t.Errorf("hook env: got %q", got)
+	}
+	if !strings.Contains(log.String(), "warning: pre_start hook") || !strings.HasSuffix(log.String(), "done\n") {
+		t.Errorf("log = %q", log.String())
+	}
+
+	// the creates path exists now, so the first hook is skipped
+	env["JAVA_HOME"] = "/opt/other"
+	if err := RunHooks(StagePreStart, hooks[:1], env, &log); err != nil {
+		t.Fatalf("RunHooks: %v", err)
+	}
+	if got, _ := os.ReadFile(formatted); string(got) != "/opt/jdk\n" {
+		t.Errorf("hook with existing creates path was run: %q", got)
+	}
+
+	err := RunHooks(StagePostStart, []config.HookConfig{
+		{Command: sh("exec sleep 30"), Timeout: config.Duration(100 * time.Millisecond)},
+		{Command: sh("touch " + filepath.Join(dir, "never"))},
+	}, nil, &log)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("timeout: err = %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "never")); statErr == nil {
+		t.Error("hook after an aborting failure was run")
+	}
+}
+
+func TestService_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	var log bytes.Buffer
+	svc := New("kafka", config.ServiceConfig{
+		Executable:     "/bin/sh",
+		ExecutableArgs: []string{"-c", "exit 0"},
+		PreStart:       []config.HookConfig{{Command: sh("false")}},
+		PostStop:       []config.HookConfig{{Command: sh("touch " + filepath.Join(dir, "cleaned"))}},
+	}, nil)
+	svc.Log = &log
+	if err := svc.Start(); err == nil || svc.Status().PID != 0 {
+		t.Fatalf("failing pre_start: err = %v, status = %+v", err, svc.Status())
+	}
+
+	svc.Config.PreStart = nil
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	_ = svc.Wait()
+	if _, err := os.Stat(filepath.Join(dir, "cleaned")); err != nil {
+		t.Errorf("post_stop hook not run: %v", err)
+	}
+}
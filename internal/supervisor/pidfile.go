@@ -0,0 +1,85 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	pidFileMode    = 0o644
+	pidFileDirMode = 0o755
+)
+
+// checkPidFile fails when path holds the PID of a running process and removes
+// it when the process is gone.
+func checkPidFile(path string, log io.Writer) error {
+	pid, err := readPidFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(log, "warning: removing invalid pidfile %s: %v\n", path, err)
+		return os.Remove(path)
+	}
+	if processExists(pid) {
+		return fmt.Errorf("pidfile %s: process %d is running", path, pid)
+	}
+	fmt.Fprintf(log, "warning: removing stale pidfile %s of process %d\n", path, pid)
+	return os.Remove(path)
+}
+
+// writePidFile writes pid to path atomically, creating its directory if needed.
+func writePidFile(path string, pid int) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, pidFileDirMode); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = fmt.Fprintf(tmp, "%d\n", pid); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(pidFileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// removePidFile removes path if it still holds pid.
+func removePidFile(path string, pid int) error {
+	if current, err := readPidFile(path); err != nil || current != pid {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+func readPidFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid %q", strings.TrimSpace(string(data)))
+	}
+	return pid, nil
+}
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
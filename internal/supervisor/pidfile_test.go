@@ -0,0 +1,82 @@
+package supervisor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestService_PidFile(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "run", "zookeeper", "zookeeper.pid")
+	svc := shellService("zookeeper", "exec sleep 30", func(c *config.ServiceConfig) {
+		c.PidFile = pidfile
+		c.HealthChecks = []config.HealthCheckConfig{{
+			Type:   "file",
+			Params: map[string]any{"path": pidfile, "non_empty": "true", "modified_after_start": "true"},
+		}}
+	})
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	got, err := os.ReadFile(pidfile)
+	if err != nil || strings.TrimSpace(string(got)) != strconv.Itoa(svc.Status().PID) {
+		t.Errorf("pidfile = %q, %v, want %d", got, err, svc.Status().PID)
+	}
+
+	// a second instance must not start while the first one runs
+	second := shellService("zookeeper", "exec sleep 30", func(c *config.ServiceConfig) { c.PidFile = pidfile })
+	if err = second.Start(); err == nil || !strings.Contains(err.Error(), "is running") {
+		t.Errorf("second Start = %v, want running error", err)
+		_ = second.Stop()
+		_ = second.Wait()
+	}
+
+	_ = svc.Stop()
+	_ = svc.Wait()
+	if _, err = os.Stat(pidfile); !os.IsNotExist(err) {
+		t.Errorf("pidfile after exit: %v", err)
+	}
+}
+
+func TestCheckPidFile_Stale(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "kafka.pid")
+	// no process can have this PID: it is above the kernel limit
+	if err := os.WriteFile(pidfile, []byte("2147483647\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var log bytes.Buffer
+	if err := checkPidFile(pidfile, &log); err != nil {
+		t.Fatalf("checkPidFile: %v", err)
+	}
+	if _, err := os.Stat(pidfile); !os.IsNotExist(err) || !strings.Contains(log.String(), "stale") {
+		t.Errorf("stale pidfile kept: %v, log %q", err, log.String())
+	}
+
+	if err := os.WriteFile(pidfile, []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := checkPidFile(pidfile, &log); err != nil {
+		t.Fatalf("checkPidFile invalid: %v", err)
+	}
+	if err := checkPidFile(pidfile, &log); err != nil {
+		t.Errorf("checkPidFile missing: %v", err)
+	}
+}
+
+func TestRemovePidFile_KeepsForeign(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "hbase.pid")
+	if err := writePidFile(pidfile, 100); err != nil {
+		t.Fatalf("writePidFile: %v", err)
+	}
+	if err := removePidFile(pidfile, 200); err != nil {
+		t.Fatalf("removePidFile: %v", err)
+	}
+	if _, err := os.Stat(pidfile); err != nil {
+		t.Errorf("pidfile of another process removed: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// pAll is P_ALL of waitid(2).
+	pAll = 0
+	// siginfoPidOffset is the offset of si_pid in siginfo_t on 64-bit Linux.
+	siginfoPidOffset = 16
+	siginfoSize      = 128
+	// reapInterval is how often zombies are looked for without SIGCHLD, e.g. when an
+	// orphan exited while a supervised process was waiting to be collected.
+	reapInterval = time.Second
+)
+
+// reaper collects orphaned processes re-parented to this process, which is
+// the duty of PID 1 in a container. Supervised processes are left to their Service,
+// whose Wait needs their exit status, and nothing is collected while a command
+// started by this process holds the reaper, see hold.
+type reaper struct {
+	mu    sync.Mutex
+	owned func(pid int) bool
+	// held counts the running commands started by this process, e.g. hooks.
+	held int
+}
+
+// hold stops the reaping until release is called, so that a command started by
+// this process meanwhile is collected by its own Wait. A nil reaper holds nothing.
+func (r *reaper) hold() (release func()) {
+	if r == nil {
+		return func() {}
+	}
+	r.mu.Lock()
+	r.held++
+	r.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			r.held--
+			r.mu.Unlock()
+		})
+	}
+}
+
+// run reaps zombies on SIGCHLD until ctx is cancelled.
+func (r *reaper) run(ctx context.Context) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGCHLD)
+	defer signal.Stop(sigs)
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reap()
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap collects the exited children that are not supervised. It stops at the
+// first supervised one, which is collected by its Service, and does nothing
+// while the reaper is held.
+func (r *reaper) reap() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.held > 0 {
+		return
+	}
+	for {
+		pid := peekExited()
+		if pid <= 0 || r.owned(pid) {
+			return
+		}
+		var ws syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil); err != nil {
+			return
+		}
+	}
+}
+
+// peekExited returns the PID of an exited child without collecting it, 0 if there is none.
+func peekExited() int {
+	var info [siginfoSize]byte
+	_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pAll, 0, uintptr(unsafe.Pointer(&info[0])),
+		syscall.WEXITED|syscall.WNOHANG|syscall.WNOWAIT, 0, 0)
+	if errno != 0 {
+		return 0
+	}
+	return int(*(*int32)(unsafe.Pointer(&info[siginfoPidOffset])))
+}
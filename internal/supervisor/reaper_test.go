@@ -0,0 +1,81 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+// prSetChildSubreaper is PR_SET_CHILD_SUBREAPER of prctl(2).
+const prSetChildSubreaper = 36
+
+func TestGroup_ReapsOrphans(t *testing.T) {
+	// Orphans of the service are re-parented to the test process, as they would be to PID 1.
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+		t.Skipf("prctl(PR_SET_CHILD_SUBREAPER): %v", errno)
+	}
+	svc := shellService("parent", "(sleep 0.1 &); exec sleep 30", nil)
+	g, err := newGroup(true, svc)
+	if err != nil {
+		t.Fatalf("newGroup: %v", err)
+	}
+	if err = g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	time.Sleep(200 * time.Millisecond)
+	for peekExited() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("orphan zombie was not reaped")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	cancel()
+	if err = <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	// the supervised process itself is still collected by its Service
+	if code := svc.Status().LastExitCode; code == nil || *code != 128+int(syscall.SIGTERM) {
+		t.Errorf("exit code = %v, want SIGTERM status", code)
+	}
+}
+
+func TestGroup_ReaperLeavesHooks(t *testing.T) {
+	// the hooks exit while the reaper runs, their exit status belongs to RunHooks
+	var hook []config.HookConfig
+	for range 20 {
+		hook = append(hook, config.HookConfig{Command: []string{"/bin/true"}})
+	}
+	svc := shellService("flaky", "exit 3", func(c *config.ServiceConfig) {
+		c.Restart = &config.RestartPolicy{Policy: config.RestartOnFailure, MaxRestarts: 3}
+		c.PreStart, c.PostStart, c.PostStop = hook, hook, hook
+	})
+	var log bytes.Buffer
+	svc.Log = &log
+	g, err := newGroup(true, svc)
+	if err != nil {
+		t.Fatalf("newGroup: %v", err)
+	}
+	if err = g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err = g.Run(context.Background()); err == nil || strings.Contains(err.Error(), "hook") {
+		t.Fatalf("Run = %v, want the exit status of the service", err)
+	}
+	if st := svc.Status(); st.Restarts != 3 {
+		t.Errorf("restarts = %d, want 3", st.Restarts)
+	}
+	if strings.Contains(log.String(), "hook") {
+		t.Errorf("hook failures: %s", log.String())
+	}
+}
@@ -0,0 +1,112 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Reload swaps in the configuration, environment, runtimes and truststore of next,
+// the service of the same name built from a reloaded configuration, without
+// restarting the running process: health checks, the restart policy, hooks and
+// stop_timeout apply from their next use. Health checks run at start and on
+// CheckHealth only, there are no liveness checks watching a running process. It returns the changes that only take
+// effect once the process is started again, such as a new executable, arguments
+// or runtime path. With restart_on_change, a running service is then restarted
+// by Supervise.
+func (s *Service) Reload(next *Service) []string {
+	s.mu.Lock()
+	changes := restartChanges(s, next)
+	s.Config = next.Config
+	s.Env = next.Env
+	s.Runtimes = next.Runtimes
+	s.TrustStore = next.TrustStore
+	restart := len(changes) > 0 && next.Config.RestartOnChange && s.status.PID != 0
+	if restart {
+		s.restartPending = true
+	}
+	s.mu.Unlock()
+
+	switch {
+	case len(changes) == 0:
+		fmt.Fprintf(s.log(), "reloaded configuration of %s\n", s.Name)
+	case restart:
+		fmt.Fprintf(s.log(), "reloaded configuration of %s, restarting for changed %s\n",
+			s.Name, strings.Join(changes, ", "))
+		if err := s.Stop(); err != nil {
+			s.takeRestartPending()
+			fmt.Fprintf(s.log(), "warning: restart %s: %v\n", s.Name, err)
+		}
+	default:
+		fmt.Fprintf(s.log(), "reloaded configuration of %s, changed %s take effect on the next restart\n",
+			s.Name, strings.Join(changes, ", "))
+	}
+	return changes
+}
+
+// restartChanges lists what differs between cur and next and needs the process
+// to be started again.
+func restartChanges(cur, next *Service) []string {
+	var changes []string
+	if cur.Config.Executable != next.Config.Executable {
+		changes = append(changes, "executable")
+	}
+	if !slices.Equal(cur.Config.ExecutableArgs, next.Config.ExecutableArgs) {
+		changes = append(changes, "executable_args")
+	}
+	if !maps.Equal(cur.Env, next.Env) {
+		changes = append(changes, "environment")
+	}
+	paths := make(map[string]string, len(cur.Runtimes))
+	for _, rt := range cur.Runtimes {
+		paths[rt.Name] = rt.Path
+	}
+	for _, rt := range next.Runtimes {
+		if path, ok := paths[rt.Name]; !ok || path != rt.Path {
+			changes = append(changes, "runtime "+rt.Name)
+		}
+	}
+	if !reflect.DeepEqual(cur.Config.Output, next.Config.Output) {
+		changes = append(changes, "output")
+	}
+	if cur.Config.PidFile != next.Config.PidFile {
+		changes = append(changes, "pidfile")
+	}
+	return changes
+}
+
+// Reload validates the services built from a reloaded configuration and swaps
+// them into the services of the group of the same name, see Service.Reload.
+// The start order and dependencies of the group are kept and services outside
+// the group are ignored. When a service is missing or invalid, nothing is
+// reloaded and the error is returned.
+func (g *Group) Reload(next ...*Service) error {
+	byName := make(map[string]*Service, len(next))
+	for _, svc := range next {
+		byName[svc.Name] = svc
+	}
+	var errs []error
+	for _, svc := range g.services {
+		n, ok := byName[svc.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("service %s is missing", svc.Name))
+			continue
+		}
+		if err := validateRestart(n.Config.Restart); err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", svc.Name, err))
+		}
+		if err := ValidateHooks(n.Config); err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", svc.Name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	for _, svc := range g.services {
+		svc.Reload(byName[svc.Name])
+	}
+	return nil
+}
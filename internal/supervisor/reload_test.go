@@ -0,0 +1,125 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestService_ReloadWithoutRestart(t *testing.T) {
+	var log bytes.Buffer
+	svc := shellService("app", "exec sleep 30", nil)
+	svc.Log = &log
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer func() {
+		_ = svc.Stop()
+		_ = svc.Wait()
+	}()
+	pid := svc.Status().PID
+
+	next := shellService("app", "exec sleep 60", func(c *config.ServiceConfig) {
+		c.Restart = &config.RestartPolicy{Policy: config.RestartAlways}
+		c.StopTimeout = config.Duration(time.Second)
+	})
+	next.Runtimes = []Runtime{{Name: "java", Path: "/opt/jdk"}}
+	changes := svc.Reload(next)
+	if got := strings.Join(changes, ","); got != "executable_args,runtime java" {
+		t.Errorf("changes = %q", got)
+	}
+	if svc.config().Restart == nil || svc.config().StopTimeout.Std() != time.Second {
+		t.Errorf("config not swapped: %+v", svc.config())
+	}
+	if st := svc.Status(); st.PID != pid || st.Restarts != 0 {
+		t.Errorf("status = %+v, want the process %d kept", st, pid)
+	}
+	if !strings.Contains(log.String(), "take effect on the next restart") {
+		t.Errorf("log = %q", log.String())
+	}
+
+	if changes = svc.Reload(shellService("app", "exec sleep 60", nil)); len(changes) != 0 {
+		t.Errorf("changes = %q, want none", changes)
+	}
+}
+
+func TestGroup_ReloadRestartOnChange(t *testing.T) {
+	svc := shellService("app", "exec sleep 30", nil)
+	svc.Log = &bytes.Buffer{}
+	g, err := NewGroup(svc)
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	if err = g.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Run(ctx) }()
+	pid := svc.Status().PID
+
+	next := shellService("app", "exec sleep 31", func(c *config.ServiceConfig) { c.RestartOnChange = true })
+	if err = g.Reload(next); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.Status().State != StateRunning || svc.Status().PID == pid {
+		if time.Now().After(deadline) {
+			t.Fatalf("not restarted: %+v", svc.Status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if st := svc.Status(); st.Restarts != 1 {
+		t.Errorf("status = %+v, want 1 restart", st)
+	}
+	cancel()
+	if err = <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func TestGroup_ReloadInvalid(t *testing.T) {
+	svc := shellService("app", "true", nil)
+	g, err := NewGroup(svc)
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	bad := shellService("app", "false", func(c *config.ServiceConfig) {
+		c.Restart = &config.RestartPolicy{Policy: "sometimes"}
+	})
+	if err = g.Reload(bad); err == nil || !strings.Contains(err.Error(), "unknown restart policy") {
+		t.Errorf("err = %v", err)
+	}
+	if err = g.Reload(shellService("other", "true", nil)); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("err = %v", err)
+	}
+	if got := svc.config().ExecutableArgs[1]; got != "true" {
+		t.Errorf("config changed by an invalid reload: %q", got)
+	}
+}
+
+func TestGroup_HandleSignalsReload(t *testing.T) {
+	g, err := NewGroup(shellService("app", "true", nil))
+	if err != nil {
+		t.Fatalf("NewGroup: %v", err)
+	}
+	reloaded := make(chan struct{}, 1)
+	ctx, stop := g.HandleSignals(context.Background(), func() { reloaded <- struct{}{} })
+	defer stop()
+	if err = syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP did not reload")
+	}
+	if ctx.Err() != nil {
+		t.Error("SIGHUP cancelled the context")
+	}
+}
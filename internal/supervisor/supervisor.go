@@ -0,0 +1,476 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+	"github.com/arenadata/ad-runtime-utils/internal/exec"
+	"github.com/arenadata/ad-runtime-utils/internal/output"
+)
+
+// States of a supervised service.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateFailed   = "failed"
+	StateExited   = "exited"
+)
+
+const (
+	// DefaultStopTimeout is used when the service has no stop_timeout.
+	DefaultStopTimeout = 10 * time.Second
+	signaledExitBase   = 128
+)
+
+// ErrNotRunning is returned when an operation needs the service process but it is not running.
+var ErrNotRunning = errors.New("service is not running")
+
+// ErrChecksRunning is returned by CheckHealth when the health checks did not
+// complete in time, along with the results of the last completed run.
+var ErrChecksRunning = errors.New("health checks are still running")
+
+// CheckResult is the outcome of the last run of a health check.
+type CheckResult struct {
+	Type     string        `json:"type"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	At       time.Time     `json:"at"`
+}
+
+// Runtime is the runtime detected for a supervised service.
+type Runtime struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+}
+
+// Status is a snapshot of the state of a supervised service.
+type Status struct {
+	Name         string        `json:"name"`
+	State        string        `json:"state"`
+	PID          int           `json:"pid,omitempty"`
+	StartedAt    time.Time     `json:"started_at,omitzero"`
+	Restarts     int           `json:"restarts"`
+	LastExitCode *int          `json:"last_exit_code,omitempty"`
+	Checks       []CheckResult `json:"checks,omitempty"`
+	Runtimes     []Runtime     `json:"runtimes,omitempty"`
+}
+
+// Service is a service process started and watched by ad-runtime-utils.
+// Env holds the environment of the process, including the detected runtime variables,
+// Runtimes the detected runtimes themselves. Stdout and Stderr are the streams
+// inherited by the process, os.Stdout and os.Stderr when nil, see config.OutputConfig.
+// Hook output and warnings go to Log, os.Stderr when nil. TrustStore is the
+// truststore tls health checks verify against unless they name their own.
+// Once the service is started, Config, Env, Runtimes and TrustStore are only
+// replaced through Reload.
+type Service struct {
+	Name       string
+	Config     config.ServiceConfig
+	Env        map[string]string
+	Runtimes   []Runtime
+	TrustStore string
+	Stdout     io.Writer
+	Stderr     io.Writer
+	Log        io.Writer
+
+	mu     sync.Mutex
+	cmd    *osexec.Cmd
+	out    *output.Output
+	target exec.Target
+	status Status
+	// pidFile is the pidfile written for the running process.
+	pidFile string
+	// restartPending makes Supervise restart the service after a reload.
+	restartPending bool
+	// reaper, when set, must not reap the process between its start and its registration.
+	reaper *reaper
+	// checks is the run of the health checks in progress, shared by its callers.
+	checks *checkRun
+}
+
+// checkRun is a run of the health checks of a service.
+type checkRun struct {
+	pid     int
+	done    chan struct{}
+	results []CheckResult
+	err     error
+}
+
+// New returns a Service that is not started yet.
+func New(name string, cfg config.ServiceConfig, env map[string]string) *Service {
+	return &Service{
+		Name:   name,
+		Config: cfg,
+		Env:    env,
+		status: Status{Name: name, State: StateStarting},
+	}
+}
+
+// Start runs the pre_start hooks, starts the service process, runs its health
+// checks one after another and then the post_start hooks. The process runs in its
+// own process group. If a health check or a post_start hook fails, the process is
+// stopped and the error is returned.
+func (s *Service) Start() error {
+	cfg, env := s.current()
+	if err := s.runHooks(StagePreStart, cfg.PreStart, env); err != nil {
+		s.setState(StateFailed)
+		return err
+	}
+	if cfg.PidFile != "" {
+		if err := checkPidFile(cfg.PidFile, s.log()); err != nil {
+			s.setState(StateFailed)
+			return err
+		}
+	}
+	if err := s.spawn(); err != nil {
+		return err
+	}
+	if _, err := s.RunHealthChecks(); err != nil {
+		s.setState(StateFailed)
+		if stopErr := s.Stop(); stopErr != nil {
+			return fmt.Errorf("failed to stop process: %w", stopErr)
+		}
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	if err := s.runHooks(StagePostStart, cfg.PostStart, env); err != nil {
+		s.setState(StateFailed)
+		if stopErr := s.Stop(); stopErr != nil {
+			return fmt.Errorf("failed to stop process: %w", stopErr)
+		}
+		return err
+	}
+	s.setState(StateRunning)
+	return nil
+}
+
+func (s *Service) spawn() error {
+	if s.reaper != nil {
+		s.reaper.mu.Lock()
+		defer s.reaper.mu.Unlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out, err := output.Open(s.Config.Output, orDefault(s.Stdout, os.Stdout), orDefault(s.Stderr, os.Stderr))
+	if err != nil {
+		s.cmd = nil
+		s.status.State = StateFailed
+		return err
+	}
+	target := exec.Target{Offsets: make(map[string]int64), TrustStore: s.TrustStore}
+	stdout, stderr := out.Stdout, out.Stderr
+	for _, check := range s.Config.HealthChecks {
+		params, decodeErr := check.DecodeParams()
+		logParams, ok := params.(*config.LogCheckParams)
+		if decodeErr != nil || !ok {
+			continue
+		}
+		// log checks only look at what is written after the process start
+		if file := logParams.File; file != "" {
+			if info, statErr := os.Stat(file); statErr == nil {
+				target.Offsets[file] = info.Size()
+			}
+		} else if target.Output == nil {
+			target.Output = output.NewCapture(0)
+			stdout = io.MultiWriter(stdout, target.Output.Writer(output.StreamStdout))
+			stderr = io.MultiWriter(stderr, target.Output.Writer(output.StreamStderr))
+		}
+	}
+	target.StartedAt = time.Now()
+	cmd, err := exec.RunExecutableGroupAsync(s.Config.Executable, s.Config.ExecutableArgs, s.Env, stdout, stderr)
+	if err != nil {
+		_ = out.Close()
+		s.cmd = nil
+		s.status.State = StateFailed
+		return err
+	}
+	s.cmd = cmd
+	s.out = out
+	target.PID = cmd.Process.Pid
+	s.target = target
+	s.status.PID = cmd.Process.Pid
+	s.status.StartedAt = target.StartedAt
+	s.status.Checks = nil
+	s.pidFile = s.Config.PidFile
+	if s.pidFile != "" {
+		if err = writePidFile(s.pidFile, target.PID); err != nil {
+			fmt.Fprintf(s.log(), "warning: write pidfile of %s: %v\n", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// RunHealthChecks runs all health checks of the service against the running process
+// and records their results. It stops at the first failing check. Concurrent
+// callers share one run instead of running the checks in parallel.
+func (s *Service) RunHealthChecks() ([]CheckResult, error) {
+	return s.CheckHealth(context.Background())
+}
+
+// CheckHealth is like RunHealthChecks, but waits for the run at most until ctx
+// is done. It then returns the results of the last completed run and
+// ErrChecksRunning, the run goes on and records its results when it completes.
+func (s *Service) CheckHealth(ctx context.Context) ([]CheckResult, error) {
+	run, err := s.startChecks()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-run.done:
+		return run.results, run.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return append([]CheckResult(nil), s.status.Checks...), ErrChecksRunning
+	}
+}
+
+// startChecks returns the run of the health checks in progress, starting one if none is.
+func (s *Service) startChecks() (*checkRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.target.PID == 0 {
+		return nil, ErrNotRunning
+	}
+	if s.checks != nil && s.checks.pid == s.target.PID {
+		return s.checks, nil
+	}
+	run := &checkRun{pid: s.target.PID, done: make(chan struct{})}
+	s.checks = run
+	target, checks := s.target, s.Config.HealthChecks
+	go func() {
+		run.results, run.err = runHealthChecks(checks, target)
+		s.mu.Lock()
+		// a process started meanwhile has its own results
+		if s.target.PID == target.PID {
+			s.status.Checks = run.results
+		}
+		if s.checks == run {
+			s.checks = nil
+		}
+		s.mu.Unlock()
+		close(run.done)
+	}()
+	return run, nil
+}
+
+func runHealthChecks(checks []config.HealthCheckConfig, target exec.Target) ([]CheckResult, error) {
+	var results []CheckResult
+	var err error
+	for _, checkCfg := range checks {
+		var check exec.HealthCheck
+		res := CheckResult{Type: checkCfg.Type, At: time.Now()}
+		if check, err = exec.NewHealthCheck(checkCfg, target); err == nil {
+			err = check.Check()
+		}
+		res.Duration = time.Since(res.At)
+		res.Healthy = err == nil
+		if err != nil {
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+		if err != nil {
+			break
+		}
+	}
+	return results, err
+}
+
+// Wait waits for the service process to exit, records its exit code, 128 + the
+// signal number when it was killed by a signal, and runs the post_stop hooks,
+// whose failures are only reported.
+// A service that failed its health checks stays in the failed state.
+func (s *Service) Wait() error {
+	s.mu.Lock()
+	cmd := s.cmd
+	s.mu.Unlock()
+	if cmd == nil {
+		return ErrNotRunning
+	}
+	err := cmd.Wait()
+
+	s.mu.Lock()
+	if s.out != nil {
+		if closeErr := s.out.Close(); closeErr != nil {
+			fmt.Fprintf(s.log(), "warning: close output of %s: %v\n", s.Name, closeErr)
+		}
+		s.out = nil
+	}
+	if s.pidFile != "" {
+		if rmErr := removePidFile(s.pidFile, cmd.Process.Pid); rmErr != nil {
+			fmt.Fprintf(s.log(), "warning: remove pidfile of %s: %v\n", s.Name, rmErr)
+		}
+		s.pidFile = ""
+	}
+	code := exitStatus(cmd.ProcessState)
+	s.status.LastExitCode = &code
+	if s.status.State != StateFailed {
+		s.status.State = StateExited
+	}
+	s.status.PID = 0
+	s.target.PID = 0
+	postStop, env := s.Config.PostStop, s.Env
+	s.mu.Unlock()
+
+	if hookErr := s.runHooks(StagePostStop, postStop, env); hookErr != nil {
+		fmt.Fprintf(s.log(), "warning: %v\n", hookErr)
+	}
+	return err
+}
+
+// Supervise waits for the started service and restarts it according to its restart
+// policy, or right away when a reload asked for it, until ctx is cancelled or the
+// policy gives up. It returns the error of the last run: nil when the service exited
+// successfully or was stopped through ctx.
+func (s *Service) Supervise(ctx context.Context) error {
+	for {
+		err := s.Wait()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !s.takeRestartPending() {
+			if !s.shouldRestart(err) {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(s.config().Restart.Delay.Std()):
+			}
+		}
+
+		s.mu.Lock()
+		s.status.Restarts++
+		s.status.State = StateStarting
+		s.mu.Unlock()
+		if err = s.Start(); err != nil && s.pid() == 0 {
+			return err
+		}
+		if ctx.Err() != nil {
+			_ = s.Stop()
+		}
+	}
+}
+
+// shouldRestart reports whether the restart policy asks for a restart after
+// a run that ended with err.
+func (s *Service) shouldRestart(err error) bool {
+	policy := s.config().Restart
+	if policy == nil {
+		return false
+	}
+	if policy.MaxRestarts > 0 && s.Status().Restarts >= policy.MaxRestarts {
+		return false
+	}
+	switch policy.Policy {
+	case config.RestartAlways:
+		return true
+	case config.RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// Stop sends SIGTERM to the process group of the service and SIGKILL once
+// stop_timeout passes without the process exiting.
+func (s *Service) Stop() error {
+	pid := s.pid()
+	if pid == 0 {
+		return ErrNotRunning
+	}
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	timeout := s.config().StopTimeout.Std()
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	time.AfterFunc(timeout, func() {
+		if s.pid() == pid {
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	})
+	return nil
+}
+
+// Signal sends sig to the process group of the service.
+func (s *Service) Signal(sig syscall.Signal) error {
+	pid := s.pid()
+	if pid == 0 {
+		return ErrNotRunning
+	}
+	return syscall.Kill(-pid, sig)
+}
+
+// Status returns a snapshot of the service state.
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status
+	st.Checks = append([]CheckResult(nil), s.status.Checks...)
+	st.Runtimes = s.Runtimes
+	return st
+}
+
+// exitStatus returns the exit code of the process the way shells report it.
+func exitStatus(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return signaledExitBase + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+func (s *Service) log() io.Writer {
+	return orDefault(s.Log, os.Stderr)
+}
+
+func orDefault(w, def io.Writer) io.Writer {
+	if w == nil {
+		return def
+	}
+	return w
+}
+
+// config returns the current configuration of the service.
+func (s *Service) config() config.ServiceConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Config
+}
+
+// current returns the current configuration and environment of the service.
+func (s *Service) current() (config.ServiceConfig, map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Config, s.Env
+}
+
+func (s *Service) takeRestartPending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.restartPending
+	s.restartPending = false
+	return pending
+}
+
+func (s *Service) pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.PID
+}
+
+func (s *Service) setState(state string) {
+	s.mu.Lock()
+	s.status.State = state
+	s.mu.Unlock()
+}
@@ -0,0 +1,227 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/arenadata/ad-runtime-utils/internal/config"
+)
+
+func TestService_StartStatusStop(t *testing.T) {
+	svc := New("sleeper", config.ServiceConfig{Executable: "/bin/sleep", ExecutableArgs: []string{"30"}}, nil)
+	if _, err := svc.RunHealthChecks(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("RunHealthChecks before start = %v, want ErrNotRunning", err)
+	}
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	st := svc.Status()
+	if st.State != StateRunning || st.PID == 0 || st.StartedAt.IsZero() {
+		t.Errorf("status after start = %+v", st)
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	_ = svc.Wait()
+	st = svc.Status()
+	if st.State != StateExited || st.PID != 0 || st.LastExitCode == nil {
+		t.Errorf("status after stop = %+v", st)
+	}
+}
+
+func TestService_StartFailingCheck(t *testing.T) {
+	svc := New("broken", config.ServiceConfig{
+		Executable:     "/bin/sleep",
+		ExecutableArgs: []string{"30"},
+		HealthChecks:   []config.HealthCheckConfig{{Type: "bogus"}},
+	}, nil)
+	if err := svc.Start(); err == nil {
+		t.Fatal("expected health check error")
+	}
+	_ = svc.Wait()
+	st := svc.Status()
+	if st.State != StateFailed {
+		t.Errorf("state = %s, want %s", st.State, StateFailed)
+	}
+	if len(st.Checks) != 1 || st.Checks[0].Healthy || st.Checks[0].Error == "" {
+		t.Errorf("checks = %+v, want one failed check", st.Checks)
+	}
+}
+
+func TestService_CheckHealth(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	if err := os.WriteFile(ready, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	svc := New("ready", config.ServiceConfig{
+		Executable:     "/bin/sleep",
+		ExecutableArgs: []string{"30"},
+		HealthChecks: []config.HealthCheckConfig{{
+			Type:   "file",
+			Params: map[string]any{"path": ready, "timeout": "5s", "interval": "10ms"},
+		}},
+	}, nil)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = svc.Stop()
+		_ = svc.Wait()
+	})
+
+	// the check now polls until the file is back: callers get the last results in time
+	if err := os.Remove(ready); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results, err := svc.CheckHealth(ctx)
+	if !errors.Is(err, ErrChecksRunning) || len(results) != 1 || !results[0].Healthy {
+		t.Fatalf("CheckHealth = (%+v, %v), want the healthy start results", results, err)
+	}
+	svc.mu.Lock()
+	first := svc.checks
+	svc.mu.Unlock()
+
+	// concurrent callers share the run in progress
+	done := make(chan error, 1)
+	go func() {
+		_, runErr := svc.RunHealthChecks()
+		done <- runErr
+	}()
+	time.Sleep(20 * time.Millisecond)
+	svc.mu.Lock()
+	shared := svc.checks == first && first != nil
+	svc.mu.Unlock()
+	if !shared {
+		t.Error("second caller started another run")
+	}
+	if err = os.WriteFile(ready, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Errorf("RunHealthChecks = %v", err)
+	}
+}
+
+func TestService_WaitDaemonizedChild(t *testing.T) {
+	svc := New("forker", config.ServiceConfig{
+		Executable:     "/bin/sh",
+		ExecutableArgs: []string{"-c", "sleep 30 & exit 0"},
+	}, nil)
+	// not an *os.File: the output is copied from a pipe the child keeps open
+	svc.Stdout = &bytes.Buffer{}
+	if err := svc.spawn(); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	pid := svc.pid()
+	t.Cleanup(func() { _ = syscall.Kill(-pid, syscall.SIGKILL) })
+
+	done := make(chan struct{})
+	go func() {
+		_ = svc.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wait hangs on the output of the daemonized child")
+	}
+}
+
+func TestService_StopEscalatesToKill(t *testing.T) {
+	svc := New("stubborn", config.ServiceConfig{
+		Executable:     "/bin/sh",
+		ExecutableArgs: []string{"-c", "trap '' TERM; while :; do sleep 0.1; done"},
+		StopTimeout:    config.Duration(200 * time.Millisecond),
+	}, nil)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// let the shell install its trap
+	time.Sleep(100 * time.Millisecond)
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	_ = svc.Wait()
+	if code := svc.Status().LastExitCode; code == nil || *code != 128+int(syscall.SIGKILL) {
+		t.Errorf("exit code = %v, want SIGKILL status", code)
+	}
+}
+
+func TestService_SignalProcessGroup(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "usr1")
+	svc := New("reloader", config.ServiceConfig{
+		Executable:     "/bin/sh",
+		ExecutableArgs: []string{"-c", "trap 'touch " + marker + "; exit 0' USR1; while :; do sleep 0.1; done"},
+	}, nil)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// let the shell install its trap
+	time.Sleep(100 * time.Millisecond)
+	if err := svc.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	_ = svc.Wait()
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("USR1 not delivered: %v", err)
+	}
+	if code := svc.Status().LastExitCode; code == nil || *code != 0 {
+		t.Errorf("exit code = %v, want 0", code)
+	}
+}
+
+func TestService_Output(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "svc.log")
+	var stdout bytes.Buffer
+	svc := New("chatty", config.ServiceConfig{
+		Executable:     "/bin/sh",
+		ExecutableArgs: []string{"-c", "echo out; echo err >&2"},
+		Output:         &config.OutputConfig{Mode: config.OutputBoth, File: file, StreamNames: true},
+	}, nil)
+	svc.Stdout, svc.Stderr = &stdout, io.Discard
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	_ = svc.Wait()
+
+	if stdout.String() != "[stdout] out\n" {
+		t.Errorf("inherited stdout = %q", stdout.String())
+	}
+	got, _ := os.ReadFile(file)
+	if !strings.Contains(string(got), "[stdout] out\n") || !strings.Contains(string(got), "[stderr] err\n") {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestService_LogHealthCheck(t *testing.T) {
+	svc := New("late-binder", config.ServiceConfig{
+		Executable:     "/bin/sh",
+		ExecutableArgs: []string{"-c", "echo starting; sleep 0.3; echo SERVER STARTED; exec sleep 30"},
+		HealthChecks: []config.HealthCheckConfig{{
+			Type:   "log",
+			Params: map[string]any{"pattern": "SERVER STARTED", "timeout": "5"},
+		}},
+	}, nil)
+	svc.Stdout = io.Discard
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = svc.Stop()
+		_ = svc.Wait()
+	})
+	if st := svc.Status(); st.State != StateRunning || len(st.Checks) != 1 || !st.Checks[0].Healthy {
+		t.Errorf("status = %+v", st)
+	}
+}
@@ -0,0 +1,238 @@
+package venv
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	pyvenvCfg       = "pyvenv.cfg"
+	lockHashFile    = ".ad-runtime-utils.lock.sha256"
+	lockHashPerm    = 0o644
+	requirementsArg = "-r"
+	probeTimeout    = 10 * time.Second
+)
+
+// pythonVersionRe extracts the version from the output of python --version.
+var pythonVersionRe = regexp.MustCompile(`Python (\S+)`)
+
+// Options describes the virtualenv to ensure.
+// BaseHome is the installation directory of the base interpreter (containing bin/python3).
+// BaseVersion is the expected "major.minor" (or more precise) version of the base interpreter.
+// Requirements is an optional requirements/lock file installed with pip.
+// Output receives the output of python and pip.
+type Options struct {
+	Path         string
+	BaseHome     string
+	BaseVersion  string
+	Requirements string
+	Output       io.Writer
+}
+
+// Result reports what Ensure did. Reason is empty if the virtualenv was up to date.
+type Result struct {
+	Path    string
+	Created bool
+	Reason  string
+}
+
+// Ensure makes sure that a valid virtualenv exists at opts.Path.
+// The virtualenv is (re)created from the base interpreter if it is missing, its
+// pyvenv.cfg points at a missing interpreter or one of a different version, the
+// base interpreter was upgraded in place, or the hash of the requirements file
+// changed since it was created. When the base interpreter cannot be probed, the
+// virtualenv is kept and the error returned.
+func Ensure(opts Options) (Result, error) {
+	res := Result{Path: opts.Path}
+	if opts.Path == "" {
+		return res, errors.New("venv path is empty")
+	}
+	base, err := findInterpreter(filepath.Join(opts.BaseHome, "bin"))
+	if err != nil {
+		return res, fmt.Errorf("base interpreter: %w", err)
+	}
+	lockHash, err := hashFile(opts.Requirements)
+	if err != nil {
+		return res, fmt.Errorf("hash requirements: %w", err)
+	}
+
+	if res.Reason, err = Validate(opts.Path, opts.BaseVersion, lockHash); err != nil {
+		return res, fmt.Errorf("validate venv %q: %w", opts.Path, err)
+	}
+	if res.Reason == "" {
+		return res, nil
+	}
+
+	if err = remove(opts.Path); err != nil {
+		return res, err
+	}
+	if err = run(opts.Output, base, "-m", "venv", opts.Path); err != nil {
+		return res, fmt.Errorf("create venv %q: %w", opts.Path, err)
+	}
+	if opts.Requirements != "" {
+		python := filepath.Join(opts.Path, "bin", "python")
+		if err = run(opts.Output, python, "-m", "pip", "install", requirementsArg, opts.Requirements); err != nil {
+			return res, fmt.Errorf("install requirements %q: %w", opts.Requirements, err)
+		}
+	}
+	if err = os.WriteFile(filepath.Join(opts.Path, lockHashFile), []byte(lockHash+"\n"), lockHashPerm); err != nil {
+		return res, fmt.Errorf("write lock hash: %w", err)
+	}
+	res.Created = true
+	return res, nil
+}
+
+// Validate checks the virtualenv at path and returns the reason it has to be
+// recreated, or "" if it is valid. The base interpreter recorded in pyvenv.cfg
+// is run to catch an upgrade in place to another minor version, which keeps
+// the recorded version but breaks the virtualenv. An error means that it could
+// not be run, e.g. on a timeout, which is no reason to drop a working virtualenv.
+func Validate(path, baseVersion, lockHash string) (string, error) {
+	cfg, err := ParseConfig(filepath.Join(path, pyvenvCfg))
+	if err != nil {
+		return "pyvenv.cfg not found", nil
+	}
+	if _, err = findInterpreter(filepath.Join(path, "bin")); err != nil {
+		return "venv interpreter missing", nil
+	}
+	home := cfg["home"]
+	base, err := findInterpreter(home)
+	if err != nil {
+		return fmt.Sprintf("base interpreter in %q missing", home), nil
+	}
+	version := cfg["version"]
+	if version == "" {
+		version = cfg["version_info"]
+	}
+	if !versionMatches(version, baseVersion) {
+		return fmt.Sprintf("base version %q does not match %q", version, baseVersion), nil
+	}
+	stored, _ := os.ReadFile(filepath.Join(path, lockHashFile))
+	if strings.TrimSpace(string(stored)) != lockHash {
+		return "requirements changed", nil
+	}
+	actual, err := probeVersion(base)
+	if err != nil {
+		return "", fmt.Errorf("base interpreter %s: %w", base, err)
+	}
+	if !versionMatches(actual, baseVersion) || minorVersion(actual) != minorVersion(version) {
+		return fmt.Sprintf("base interpreter %s is %q, venv was created with %q", base, actual, version), nil
+	}
+	return "", nil
+}
+
+// probeVersion returns the version reported by python --version.
+func probeVersion(python string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, python, "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	m := pythonVersionRe.FindSubmatch(out)
+	if m == nil {
+		return "", fmt.Errorf("version not found in %q", strings.TrimSpace(string(out)))
+	}
+	return string(m[1]), nil
+}
+
+// minorVersion returns the "major.minor" part of a version: a venv survives
+// patch upgrades of its base interpreter, not minor ones.
+func minorVersion(version string) string {
+	major, rest, _ := strings.Cut(version, ".")
+	minor, _, _ := strings.Cut(rest, ".")
+	return major + "." + minor
+}
+
+// ParseConfig parses a pyvenv.cfg file into a map of lower-cased keys.
+func ParseConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		cfg[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return cfg, scanner.Err()
+}
+
+// versionMatches reports whether version (e.g. "3.11.4") is want or a more precise
+// version of it ("3.11" matches "3.11.4", but not "3.1.2").
+func versionMatches(version, want string) bool {
+	if want == "" {
+		return true
+	}
+	return version == want || strings.HasPrefix(version, want+".")
+}
+
+func findInterpreter(binDir string) (string, error) {
+	if binDir == "" {
+		return "", errors.New("no interpreter directory")
+	}
+	for _, name := range []string{"python3", "python"} {
+		p := filepath.Join(binDir, name)
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no python interpreter in %s", binDir)
+}
+
+// hashFile returns the hex sha256 of the file, or "" if path is empty.
+func hashFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// remove deletes an existing virtualenv. Non-empty directories without
+// pyvenv.cfg are never removed to avoid wiping a wrongly configured path.
+func remove(path string) error {
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(filepath.Join(path, pyvenvCfg)); err != nil {
+		return fmt.Errorf("refusing to replace %q: not a virtualenv", path)
+	}
+	return os.RemoveAll(path)
+}
+
+func run(out io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
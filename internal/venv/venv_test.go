@@ -0,0 +1,161 @@
+package venv
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePython is a base interpreter that understands "-m venv <path>" and
+// "-m pip install -r <file>" well enough for Ensure.
+const fakePython = `#!/bin/sh
+if [ -n "$FAKE_PYTHON_FAIL" ]; then
+  exit 1
+fi
+if [ "$1" = "--version" ]; then
+  echo "Python $FAKE_PYTHON_VERSION"
+  exit 0
+fi
+if [ "$1 $2" = "-m venv" ]; then
+  mkdir -p "$3/bin"
+  printf 'home = %s\nversion = %s\n' "$(dirname "$0")" "$FAKE_PYTHON_VERSION" > "$3/pyvenv.cfg"
+  cp "$0" "$3/bin/python"
+  exit 0
+fi
+if [ "$1 $2 $3" = "-m pip install" ]; then
+  cp "$5" "$(dirname "$0")/../installed.txt"
+  exit 0
+fi
+exit 1
+`
+
+func makeBase(t *testing.T, dir string) string {
+	t.Helper()
+	bin := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "python3"), []byte(fakePython), 0o755); err != nil {
+		t.Fatalf("write python: %v", err)
+	}
+	return dir
+}
+
+func TestEnsure_CreateAndKeep(t *testing.T) {
+	t.Setenv("FAKE_PYTHON_VERSION", "3.11.4")
+	tmp := t.TempDir()
+	base := makeBase(t, filepath.Join(tmp, "python3.11"))
+	reqs := filepath.Join(tmp, "requirements.lock")
+	if err := os.WriteFile(reqs, []byte("apache-airflow==2.9.3\n"), 0o644); err != nil {
+		t.Fatalf("write requirements: %v", err)
+	}
+	opts := Options{
+		Path:         filepath.Join(tmp, "venv"),
+		BaseHome:     base,
+		BaseVersion:  "3.11",
+		Requirements: reqs,
+		Output:       &bytes.Buffer{},
+	}
+
+	res, err := Ensure(opts)
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if !res.Created || res.Reason != "pyvenv.cfg not found" {
+		t.Errorf("first run = %+v, want created because pyvenv.cfg not found", res)
+	}
+	if _, err = os.Stat(filepath.Join(opts.Path, "installed.txt")); err != nil {
+		t.Errorf("requirements not installed: %v", err)
+	}
+
+	res, err = Ensure(opts)
+	if err != nil || res.Created {
+		t.Errorf("second run = (%+v, %v), want untouched venv", res, err)
+	}
+
+	// the venv is kept when the base interpreter cannot be probed, e.g. on a timeout
+	t.Setenv("FAKE_PYTHON_FAIL", "1")
+	if res, err = Ensure(opts); err == nil || res.Created {
+		t.Errorf("failing probe = (%+v, %v), want an error", res, err)
+	}
+	if _, err = os.Stat(filepath.Join(opts.Path, "installed.txt")); err != nil {
+		t.Errorf("venv removed after a failing probe: %v", err)
+	}
+	t.Setenv("FAKE_PYTHON_FAIL", "")
+
+	// lock file change forces recreation
+	if err = os.WriteFile(reqs, []byte("apache-airflow==2.10.0\n"), 0o644); err != nil {
+		t.Fatalf("write requirements: %v", err)
+	}
+	res, err = Ensure(opts)
+	if err != nil || !res.Created || res.Reason != "requirements changed" {
+		t.Errorf("after lock change = (%+v, %v), want recreated", res, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("FAKE_PYTHON_VERSION", "3.11.9")
+	tmp := t.TempDir()
+	base := makeBase(t, filepath.Join(tmp, "base"))
+	venvDir := filepath.Join(tmp, "venv")
+	makeBase(t, venvDir)
+
+	write := func(home, version string) {
+		cfg := "home = " + home + "\nversion = " + version + "\n"
+		if err := os.WriteFile(filepath.Join(venvDir, pyvenvCfg), []byte(cfg), 0o644); err != nil {
+			t.Fatalf("write pyvenv.cfg: %v", err)
+		}
+	}
+
+	write(filepath.Join(base, "bin"), "3.11.9")
+	if reason, err := Validate(venvDir, "3.11", ""); reason != "" || err != nil {
+		t.Errorf("valid venv: reason = %q, %v", reason, err)
+	}
+	if reason, _ := Validate(venvDir, "3.1", ""); !strings.Contains(reason, "does not match") {
+		t.Errorf("3.1 vs 3.11.9: reason = %q, want version mismatch", reason)
+	}
+
+	// base python upgraded in place: the recorded version still matches
+	t.Setenv("FAKE_PYTHON_VERSION", "3.12.1")
+	if reason, _ := Validate(venvDir, "3.11", ""); !strings.Contains(reason, `is "3.12.1"`) {
+		t.Errorf("upgraded base: reason = %q, want upgraded interpreter", reason)
+	}
+	// a patch upgrade keeps the venv
+	t.Setenv("FAKE_PYTHON_VERSION", "3.11.10")
+	if reason, err := Validate(venvDir, "3.11", ""); reason != "" || err != nil {
+		t.Errorf("patch upgrade: reason = %q, %v", reason, err)
+	}
+	// a base python that cannot be run is no reason to recreate
+	t.Setenv("FAKE_PYTHON_FAIL", "1")
+	if reason, err := Validate(venvDir, "3.11", ""); reason != "" || err == nil {
+		t.Errorf("failing probe: reason = %q, %v, want an error", reason, err)
+	}
+	t.Setenv("FAKE_PYTHON_FAIL", "")
+
+	// base python upgraded and the old one removed
+	write(filepath.Join(tmp, "python3.10", "bin"), "3.11.9")
+	if reason, _ := Validate(venvDir, "3.11", ""); !strings.Contains(reason, "missing") {
+		t.Errorf("missing base: reason = %q, want missing interpreter", reason)
+	}
+}
+
+func TestEnsure_RefusesNonVenvDir(t *testing.T) {
+	tmp := t.TempDir()
+	base := makeBase(t, filepath.Join(tmp, "base"))
+	target := filepath.Join(tmp, "data")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "keep"), nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if _, err := Ensure(Options{Path: target, BaseHome: base, Output: &bytes.Buffer{}}); err == nil {
+		t.Fatal("expected refusal to replace a non-venv directory")
+	}
+	if _, err := os.Stat(filepath.Join(target, "keep")); err != nil {
+		t.Errorf("directory content was removed: %v", err)
+	}
+}